@@ -1,11 +1,18 @@
 package app
 
 import (
+	"bufio"
+	"crypto/ed25519"
 	"fmt"
 	"github.com/catay/rrst/config"
 	"github.com/catay/rrst/repository"
+	"github.com/catay/rrst/repository/repomd"
 	"github.com/catay/rrst/server"
+	"github.com/catay/rrst/util"
 	"os"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
 	"text/tabwriter"
 )
@@ -50,23 +57,29 @@ func (a *App) Create(action string) {
 	fmt.Println(action)
 }
 
-func (a *App) Status(repo string) {
+func (a *App) Status(repo string, tagOrRev string, labels []string, output string) error {
 	if len(a.repositories) == 0 {
-		fmt.Println("No repositories configured.")
-		return
+		return failOutput("No repositories configured.")
 	}
 
-	if repo != "" {
-		a.showRepo(repo)
+	var err error
+	if repo != "" && tagOrRev != "" {
+		err = a.showRevision(repo, tagOrRev, output)
+	} else if repo != "" {
+		err = a.showRepo(repo, labels, output)
 	} else {
-		a.showRepos()
+		err = a.showRepos(output)
+	}
+
+	if err != nil {
+		return failOutput("status error: ", err)
 	}
+	return nil
 }
 
-func (a *App) List(repo string, tagsOrRevs ...string) {
+func (a *App) List(repo string, output string, tagsOrRevs ...string) error {
 	if len(a.repositories) == 0 {
-		fmt.Println("No repositories configured.")
-		return
+		return failOutput("No repositories configured.")
 	}
 
 	if r, ok := a.getRepoName(repo); ok {
@@ -76,19 +89,45 @@ func (a *App) List(repo string, tagsOrRevs ...string) {
 		}
 		packageMap, err := r.PackageVersions(tagsOrRevs...)
 		if err != nil {
-			fmt.Println("list error: ", err)
-			return
+			return failOutput("list error: ", err)
+		}
+
+		var packages []string
+		for k := range packageMap {
+			packages = append(packages, k)
+		}
+		sort.Strings(packages)
+
+		if output != OutputText {
+			list := []PackageList{}
+			var records [][]string
+			for _, k := range packages {
+				p := newPackageList(k, tagsOrRevs, packageMap[k])
+				list = append(list, p)
+				records = append(records, p.csvRecord(tagsOrRevs))
+			}
+
+			header := append([]string{"package", "name", "arch"}, tagsOrRevs...)
+			if err := writeOutput(output, list, header, records); err != nil {
+				return failOutput("list error: ", err)
+			}
+			return nil
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
 		fmt.Fprintf(w, "PACKAGE\t%v\n", strings.Join(tagsOrRevs, "\t"))
-		for k, v := range packageMap {
-			fmt.Fprintf(w, "%v\t%v\n", k, strings.Join(v, "\t"))
+		for _, k := range packages {
+			var versions []string
+			for _, v := range packageMap[k] {
+				versions = append(versions, repository.VersionsString(v))
+			}
+			fmt.Fprintf(w, "%v\t%v\n", k, strings.Join(versions, "\t"))
 		}
 		w.Flush()
 	} else {
-		fmt.Println("No configured repository", repo, "found.")
+		return failOutput("No configured repository", repo, "found.")
 	}
+	return nil
 }
 
 func (a *App) Update(repo string, rev int64) {
@@ -115,7 +154,7 @@ func (a *App) Update(repo string, rev int64) {
 	}
 }
 
-func (a *App) Tag(repo string, tag string, rev int64, force bool) {
+func (a *App) Tag(repo string, tag string, rev int64, force bool, checkDeps bool) {
 	if len(a.repositories) == 0 {
 		fmt.Println("No repositories configured.")
 		return
@@ -123,6 +162,21 @@ func (a *App) Tag(repo string, tag string, rev int64, force bool) {
 
 	if repo != "" {
 		if r, ok := a.getRepoName(repo); ok {
+			// A tag promotion has to ship a package set that can install,
+			// unless forced.
+			if (checkDeps || r.RequireClosure) && !force {
+				unresolved, err := a.checkDeps(r, fmt.Sprintf("%v", rev), r.DependencyRepos)
+				if err != nil {
+					fmt.Println("tag error: ", err)
+					return
+				}
+				if n := unresolvedCount(unresolved); n > 0 {
+					showUnresolvedDeps(unresolved)
+					fmt.Printf("tag error:  revision %v has %v unresolved dependencies\n", rev, n)
+					return
+				}
+			}
+
 			_, err := r.Tag(tag, rev, force)
 			if err != nil {
 				fmt.Println("tag error: ", err)
@@ -133,121 +187,1120 @@ func (a *App) Tag(repo string, tag string, rev int64, force bool) {
 	}
 }
 
-func (a *App) Delete(repo string, rev int64, force bool) {
+func (a *App) DeleteTag(repo string, tag string) {
 	if len(a.repositories) == 0 {
 		fmt.Println("No repositories configured.")
 		return
 	}
 
-	if repo != "" {
-		if r, ok := a.getRepoName(repo); ok {
-			_, err := r.Delete(rev, force)
-			if err != nil {
-				fmt.Println("delete error: ", err)
-			}
-		} else {
-			fmt.Println("No configured repository", repo, "found.")
+	if r, ok := a.getRepoName(repo); ok {
+		if _, err := r.DeleteTag(tag); err != nil {
+			fmt.Println("tag error: ", err)
 		}
+	} else {
+		fmt.Println("No configured repository", repo, "found.")
 	}
 }
 
-func (a *App) Diff(repo string, tagsOrRevs ...string) {
+func (a *App) RenameTag(repo string, oldTag string, newTag string) {
 	if len(a.repositories) == 0 {
 		fmt.Println("No repositories configured.")
 		return
 	}
 
 	if r, ok := a.getRepoName(repo); ok {
-		// if only 1 tag is provided, compare with latest tag
-		if len(tagsOrRevs) == 1 {
-			tagsOrRevs = append(tagsOrRevs, config.DefaultLatestRevisionTag)
+		if _, err := r.RenameTag(oldTag, newTag); err != nil {
+			fmt.Println("tag error: ", err)
 		}
-		packageMap, err := r.PackageVersions(tagsOrRevs...)
+	} else {
+		fmt.Println("No configured repository", repo, "found.")
+	}
+}
+
+func (a *App) TagHistory(repo string, tag string) {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return
+	}
+
+	r, ok := a.getRepoName(repo)
+	if !ok {
+		fmt.Println("No configured repository", repo, "found.")
+		return
+	}
+
+	changes, err := r.TagHistory(tag)
+	if err != nil {
+		fmt.Println("tag error: ", err)
+		return
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("No tag changes recorded.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "TIME\tUSER\tTAG\tACTION\tPREVIOUS\tREVISION\tCOMMENT")
+	for _, c := range changes {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", c.Time.Local().Format("2006-01-02 15:04:05"), c.User, c.Tag, c.Action,
+			revisionOrNone(c.Previous), revisionOrNone(c.Revision), c.Comment)
+	}
+	w.Flush()
+}
+
+func (a *App) RollbackTag(repo string, tag string, steps int) {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return
+	}
+
+	if r, ok := a.getRepoName(repo); ok {
+		if _, err := r.RollbackTag(tag, steps); err != nil {
+			fmt.Println("tag error: ", err)
+		}
+	} else {
+		fmt.Println("No configured repository", repo, "found.")
+	}
+}
+
+func (a *App) Promote(repo string, stage string, force bool) {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return
+	}
+
+	r, ok := a.getRepoName(repo)
+	if !ok {
+		fmt.Println("No configured repository", repo, "found.")
+		return
+	}
+
+	p, err := r.NewPromotion(stage, force)
+	if err != nil {
+		fmt.Println("promote error: ", err)
+		return
+	}
+
+	if r.RequireClosure && !force {
+		unresolved, err := a.checkDeps(r, fmt.Sprintf("%v", p.Revision.Id), r.DependencyRepos)
 		if err != nil {
-			fmt.Println("diff error: ", err)
+			fmt.Println("promote error: ", err)
+			return
+		}
+		if n := unresolvedCount(unresolved); n > 0 {
+			showUnresolvedDeps(unresolved)
+			fmt.Printf("promote error:  revision %v has %v unresolved dependencies\n", p.Revision.Id, n)
 			return
 		}
+	}
 
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
-		fmt.Fprintf(w, "PACKAGE\t%v\n", strings.Join(tagsOrRevs, "\t"))
-		for k, v := range packageMap {
-			// only show packages with a different version in a tagged revision
-			var show bool
-			for _, a := range v[1:] {
-				if v[0] != a {
-					show = true
-					break
-				}
-			}
+	changed, err := r.Promote(p)
+	if err != nil {
+		fmt.Println("promote error: ", err)
+		return
+	}
 
-			if show {
-				fmt.Fprintf(w, "%v\t%v\n", k, strings.Join(v, "\t"))
-			}
-		}
-		w.Flush()
+	if changed {
+		fmt.Printf("Promoted revision %v from %v to %v\n", p.Revision.Id, p.Source, p.Stage)
 	} else {
-		fmt.Println("No configured repository", repo, "found.")
+		fmt.Printf("Stage %v already at revision %v\n", p.Stage, p.Revision.Id)
 	}
 }
 
-func (a *App) Server(port string) error {
-	s := server.NewServer(port, a.repositories)
-	return s.Run()
+func (a *App) AutoTag(repo string) {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return
+	}
+
+	repositories := a.repositories
+	if repo != "" {
+		r, ok := a.getRepoName(repo)
+		if !ok {
+			fmt.Println("No configured repository", repo, "found.")
+			return
+		}
+		repositories = []*repository.Repository{r}
+	}
+
+	for _, r := range repositories {
+		changed, err := r.ApplyTagRules()
+		for _, t := range changed {
+			fmt.Printf("%v: tag %v updated\n", r.Name, t)
+		}
+		if err != nil {
+			fmt.Printf("%v: autotag error: %v\n", r.Name, err)
+		}
+	}
 }
 
-func (a *App) isRepoName(repo string) bool {
-	for _, r := range a.repositories {
-		if repo == r.Name {
-			return true
+func (a *App) Annotate(repo string, tagOrRev string, args []string) {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return
+	}
+
+	r, ok := a.getRepoName(repo)
+	if !ok {
+		fmt.Println("No configured repository", repo, "found.")
+		return
+	}
+
+	// Arguments formatted as key=value are labels, the others form the note.
+	var words, labels []string
+	for _, v := range args {
+		if repository.IsLabel(v) {
+			labels = append(labels, v)
+		} else {
+			words = append(words, v)
 		}
 	}
-	return false
+
+	if err := r.Annotate(tagOrRev, strings.Join(words, " "), labels); err != nil {
+		fmt.Println("annotate error: ", err)
+	}
 }
 
-func (a *App) getRepoName(repo string) (*repository.Repository, bool) {
-	for i, r := range a.repositories {
-		if repo == r.Name {
-			return a.repositories[i], true
+func (a *App) Lock(repo string, tagOrRev string) {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return
+	}
+
+	r, ok := a.getRepoName(repo)
+	if !ok {
+		fmt.Println("No configured repository", repo, "found.")
+		return
+	}
+
+	if tagOrRev == "" {
+		fmt.Println("lock error:  tag or revision required")
+		return
+	}
+
+	locked, err := r.LockRevision(tagOrRev)
+	if err != nil {
+		fmt.Println("lock error: ", err)
+		return
+	}
+
+	if locked {
+		fmt.Printf("Locked %v\n", tagOrRev)
+	} else {
+		fmt.Printf("%v already locked\n", tagOrRev)
+	}
+}
+
+func (a *App) Unlock(repo string, tagOrRev string) {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return
+	}
+
+	r, ok := a.getRepoName(repo)
+	if !ok {
+		fmt.Println("No configured repository", repo, "found.")
+		return
+	}
+
+	unlocked, err := r.UnlockRevision(tagOrRev)
+	if err != nil {
+		fmt.Println("unlock error: ", err)
+		return
+	}
+
+	if unlocked {
+		fmt.Printf("Unlocked %v\n", tagOrRev)
+	} else {
+		fmt.Printf("%v not locked\n", tagOrRev)
+	}
+}
+
+// The VerifyLocks method verifies the content of a locked revision, or of
+// all the locked revisions of a repository when no tag or revision is
+// given. An error is returned when the content of a revision changed.
+func (a *App) VerifyLocks(repo string, tagOrRev string) error {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return nil
+	}
+
+	r, ok := a.getRepoName(repo)
+	if !ok {
+		fmt.Println("No configured repository", repo, "found.")
+		return nil
+	}
+
+	revisions := r.LockedRevisions()
+	if tagOrRev != "" {
+		rev, ok := r.RevisionByTagOrRevId(tagOrRev)
+		if !ok {
+			return fmt.Errorf("Tag or revision '%v' not found.", tagOrRev)
 		}
+		revisions = []*repository.Revision{rev}
 	}
-	return nil, false
+
+	var failed int
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tFILE\tPROBLEM")
+	for _, rev := range revisions {
+		mismatches, err := r.VerifyLock(rev)
+		if err != nil {
+			return err
+		}
+
+		if len(mismatches) > 0 {
+			failed++
+		}
+
+		for _, m := range mismatches {
+			fmt.Fprintf(w, "%v\t%v\t%v\n", rev.Id, m.Path, m.Problem)
+		}
+	}
+
+	if failed == 0 {
+		fmt.Printf("%v locked revision(s) verified.\n", len(revisions))
+		return nil
+	}
+
+	w.Flush()
+	return fmt.Errorf("%v of %v locked revision(s) changed since they were locked", failed, len(revisions))
 }
 
-func (a *App) initContentPath() error {
-	if err := os.MkdirAll(a.config.GlobalConfig.ContentPath, 0700); err != nil {
+// The Verify method checks the metadata and packages of a repository
+// revision, or of all revisions when no tag or revision is given, and
+// optionally repairs the missing and corrupt files. An error is returned
+// when problems remain.
+func (a *App) Verify(repo string, tagOrRev string, repair bool) error {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return nil
+	}
+
+	r, ok := a.getRepoName(repo)
+	if !ok {
+		fmt.Println("No configured repository", repo, "found.")
+		return nil
+	}
+
+	problems, err := r.Verify(tagOrRev)
+	if err != nil {
 		return err
 	}
-	return nil
-}
 
-// The showRepo method prints detailed repository information to
-// standard ouptput of the specified repository when present.
-func (a *App) showRepo(repo string) error {
-	if r, ok := a.getRepoName(repo); ok {
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
-		if r.HasRevisions() {
-			fmt.Fprintln(w, "REVISION\tCREATED\tTAGS")
-			for _, v := range r.Revisions {
-				tags := strings.Join(v.TagNames(), ", ")
-				if tags == "" {
-					tags = "<none>"
-				}
-				fmt.Fprintf(w, "%v\t%v\t%v\n", v.Id, v.Timestamp(), tags)
-			}
-		} else {
-			fmt.Fprintf(w, "No revisions available for repository %v\n.", repo)
+	if len(problems) == 0 {
+		fmt.Println("No problems found.")
+		return nil
+	}
+
+	if repair {
+		if err := r.Repair(problems); err != nil {
+			return err
 		}
-		return w.Flush()
+	}
 
+	var remaining int
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	if repair {
+		fmt.Fprintln(w, "REVISION\tFILE\tPROBLEM\tDETAIL\tREPAIRED")
 	} else {
-		fmt.Printf("Repository '%v' not found.\n", repo)
+		fmt.Fprintln(w, "REVISION\tFILE\tPROBLEM\tDETAIL")
+	}
+
+	for _, p := range problems {
+		if !p.Repaired {
+			remaining++
+		}
+
+		revision := "-"
+		if p.Revision != 0 {
+			revision = fmt.Sprint(p.Revision)
+		}
+
+		if repair {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", revision, p.Path, p.Problem, p.Detail, p.Repaired)
+		} else {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", revision, p.Path, p.Problem, p.Detail)
+		}
+	}
+	w.Flush()
+
+	if remaining > 0 {
+		return fmt.Errorf("%v problem(s) found", remaining)
 	}
 	return nil
 }
 
-// The showRepos method prints general repository information to
-// standard ouptput of all the configured repositories.
-func (a *App) showRepos() error {
+// The Fsck method checks the content path of a repository, or of all
+// repositories when no repository is given, and optionally applies the
+// safe fixes. An error is returned when problems remain.
+func (a *App) Fsck(repo string, fix bool) error {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return nil
+	}
+
+	repositories := a.repositories
+	if repo != "" {
+		r, ok := a.getRepoName(repo)
+		if !ok {
+			fmt.Println("No configured repository", repo, "found.")
+			return nil
+		}
+		repositories = []*repository.Repository{r}
+	}
+
+	var found, remaining int
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	if fix {
+		fmt.Fprintln(w, "REPO\tPATH\tPROBLEM\tDETAIL\tFIX\tFIXED")
+	} else {
+		fmt.Fprintln(w, "REPO\tPATH\tPROBLEM\tDETAIL\tFIX")
+	}
+
+	for _, r := range repositories {
+		problems, err := r.Fsck(fix)
+		if err != nil {
+			return fmt.Errorf("%v: %s", r.Name, err)
+		}
+
+		for _, p := range problems {
+			found++
+			if !p.Fixed {
+				remaining++
+			}
+
+			fixDesc := p.Fix
+			if fixDesc == "" {
+				fixDesc = "-"
+			}
+
+			if fix {
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", r.Name, p.Path, p.Problem, p.Detail, fixDesc, p.Fixed)
+			} else {
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", r.Name, p.Path, p.Problem, p.Detail, fixDesc)
+			}
+		}
+	}
+
+	if found == 0 {
+		fmt.Println("No problems found.")
+		return nil
+	}
+	w.Flush()
+
+	if remaining > 0 {
+		return fmt.Errorf("%v problem(s) found", remaining)
+	}
+	return nil
+}
+
+// The Export method writes a repository revision to a signed bundle.
+func (a *App) Export(repo string, tagOrRev string, since string, output string, keyFile string) error {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return nil
+	}
+
+	r, ok := a.getRepoName(repo)
+	if !ok {
+		fmt.Println("No configured repository", repo, "found.")
+		return nil
+	}
+
+	rev, ok := r.RevisionByTagOrRevId(tagOrRev)
+	if !ok {
+		return fmt.Errorf("Tag or revision '%v' not found.", tagOrRev)
+	}
+
+	if keyFile == "" {
+		keyFile = a.config.GlobalConfig.Bundle.SigningKey
+	}
+
+	if keyFile == "" {
+		return fmt.Errorf("export error: no signing key configured")
+	}
+
+	key, err := repository.LoadSigningKey(keyFile)
+	if err != nil {
+		return fmt.Errorf("export error: %s", err)
+	}
+
+	if output == "" {
+		output = fmt.Sprintf("%v-%v.tar.zst", r.Name, rev.Id)
+	}
+
+	m, err := r.Export(tagOrRev, since, output, key)
+	if err != nil {
+		return fmt.Errorf("export error: %s", err)
+	}
+
+	var packages int
+	var size int64
+	for _, p := range m.Packages {
+		if !p.Excluded {
+			packages++
+			size += p.Size
+		}
+	}
+
+	fmt.Printf("Exported revision %v of %v to %v, %v of %v packages (%v)\n",
+		m.Revision, r.Name, output, packages, len(m.Packages), util.HumanBytes(size))
+	return nil
+}
+
+// The Import method imports a repository revision from a bundle. The
+// bundle should be signed with one of the trusted keys, unless the
+// verification is skipped.
+func (a *App) Import(bundle string, repo string, tag bool, skipVerify bool) error {
+	b, err := repository.OpenBundle(bundle)
+	if err != nil {
+		return fmt.Errorf("import error: %s", err)
+	}
+	defer b.Close()
+
+	if !skipVerify {
+		var trusted []ed25519.PublicKey
+		for _, v := range a.config.GlobalConfig.Bundle.TrustedKeys {
+			k, err := repository.LoadTrustedKey(v)
+			if err != nil {
+				return fmt.Errorf("import error: %s", err)
+			}
+			trusted = append(trusted, k)
+		}
+
+		if err := b.Verify(trusted); err != nil {
+			return fmt.Errorf("import error: %s", err)
+		}
+	}
+
+	if repo == "" {
+		repo = b.Manifest.Repository
+	}
+
+	r, ok := a.getRepoName(repo)
+	if !ok {
+		return fmt.Errorf("No configured repository %v found.", repo)
+	}
+
+	res, err := r.Import(b, tag)
+	if err != nil {
+		return fmt.Errorf("import error: %s", err)
+	}
+
+	if res.Created {
+		fmt.Printf("Imported revision %v of %v as revision %v\n", b.Manifest.Revision, b.Manifest.Repository, res.Revision.Id)
+	} else {
+		fmt.Printf("Revision %v of %v already present as revision %v\n", b.Manifest.Revision, b.Manifest.Repository, res.Revision.Id)
+	}
+	fmt.Printf("Packages imported: %v, already present: %v\n", res.Imported, res.Present)
+
+	return nil
+}
+
+func (a *App) Delete(repo string, rev int64, force bool) {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return
+	}
+
+	if repo != "" {
+		if r, ok := a.getRepoName(repo); ok {
+			revisions, err := r.RevisionsToDelete(rev)
+			if err != nil {
+				fmt.Println("delete error: ", err)
+				return
+			}
+
+			if len(revisions) == 0 {
+				fmt.Println("No repository revisions to delete.")
+				return
+			}
+
+			if !force {
+				showRevisionsToDelete(revisions)
+				if !confirm(fmt.Sprintf("Delete %v revision(s) of repository %v?", len(revisions), r.Name)) {
+					fmt.Println("Aborted, nothing deleted.")
+					return
+				}
+			}
+
+			if _, err := r.Delete(rev, true); err != nil {
+				fmt.Println("delete error: ", err)
+			}
+		} else {
+			fmt.Println("No configured repository", repo, "found.")
+		}
+	}
+}
+
+func (a *App) Diff(repo string, output string, changelog bool, tagsOrRevs ...string) error {
+	if len(a.repositories) == 0 {
+		return failOutput("No repositories configured.")
+	}
+
+	// a repo:tag reference in place of the repository name is the first
+	// tag or revision to compare
+	if i := strings.LastIndex(repo, ":"); i >= 0 {
+		tagsOrRevs = append([]string{repo}, tagsOrRevs...)
+		repo = repo[:i]
+	}
+
+	if r, ok := a.getRepoName(repo); ok {
+		// if only 1 tag is provided, compare with latest tag
+		if len(tagsOrRevs) == 1 {
+			tagsOrRevs = append(tagsOrRevs, config.DefaultLatestRevisionTag)
+		}
+
+		if changelog && len(tagsOrRevs) != 2 {
+			return failOutput("diff error: ", "the changelog can only be shown between two tags or revisions")
+		}
+
+		// tags or revisions of other repositories are referenced as
+		// repo:tag or repo:revision
+		var repos []*repository.Repository
+		var refs []string
+		var packageLists [][]repomd.RpmPackage
+		for _, ref := range tagsOrRevs {
+			rr, tagOrRev := r, ref
+			if strings.Contains(ref, ":") {
+				var err error
+				if rr, tagOrRev, err = a.getRepoByRef(ref); err != nil {
+					return failOutput("diff error: ", err)
+				}
+			}
+
+			packages, err := rr.PackageIndex(tagOrRev)
+			if err != nil {
+				return failOutput("diff error: ", err)
+			}
+
+			repos = append(repos, rr)
+			refs = append(refs, tagOrRev)
+			packageLists = append(packageLists, packages)
+		}
+
+		diffs := repository.DiffPackages(packageLists...)
+
+		// only the changelogs of the upgraded packages are read
+		var upgraded []string
+		if changelog {
+			for _, d := range diffs {
+				if d.Change == repository.PackageUpgraded {
+					upgraded = append(upgraded, d.Package)
+				}
+			}
+		}
+
+		var changelogs []repository.PackageChangelog
+		if len(upgraded) > 0 {
+			from, err := repos[0].Changelogs(refs[0], upgraded...)
+			if err != nil {
+				return failOutput("diff error: ", err)
+			}
+
+			to, err := repos[1].Changelogs(refs[1], upgraded...)
+			if err != nil {
+				return failOutput("diff error: ", err)
+			}
+
+			changelogs = repository.UpgradeChangelogs(diffs, from, to)
+		}
+
+		summary := map[string]int{
+			repository.PackageAdded:      0,
+			repository.PackageRemoved:    0,
+			repository.PackageUpgraded:   0,
+			repository.PackageDowngraded: 0,
+			repository.PackageChanged:    0,
+		}
+		for _, d := range diffs {
+			summary[d.Change]++
+		}
+
+		switch output {
+		case OutputText:
+		case OutputMarkdown:
+			showDiffMarkdown(r.Name, tagsOrRevs, diffs, summary, changelogs)
+			return nil
+		default:
+			if err := showDiffOutput(tagsOrRevs, diffs, summary, changelogs, output); err != nil {
+				return failOutput("diff error: ", err)
+			}
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+		fmt.Fprintf(w, "PACKAGE\t%v\tCHANGE\n", strings.Join(tagsOrRevs, "\t"))
+		for _, d := range diffs {
+			var versions []string
+			for _, v := range d.Versions {
+				versions = append(versions, repository.VersionsString(v))
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\n", d.Package, strings.Join(versions, "\t"), d.Change)
+		}
+		w.Flush()
+
+		fmt.Printf("\n%v\n", summaryString(summary))
+
+		for _, c := range changelogs {
+			fmt.Printf("\n%v %v -> %v\n", c.Package, c.From, c.To)
+			for _, e := range c.Entries {
+				fmt.Printf("\n%v\n", e)
+			}
+		}
+	} else {
+		return failOutput("No configured repository", repo, "found.")
+	}
+	return nil
+}
+
+func (a *App) History(repo string, name string, arch string, output string) error {
+	if len(a.repositories) == 0 {
+		return failOutput("No repositories configured.")
+	}
+
+	r, ok := a.getRepoName(repo)
+	if !ok {
+		return failOutput("No configured repository", repo, "found.")
+	}
+
+	history, err := r.History(name, arch)
+	if err != nil {
+		return failOutput("history error: ", err)
+	}
+
+	if output != OutputText {
+		if err := showHistoryOutput(history, output); err != nil {
+			return failOutput("history error: ", err)
+		}
+		return nil
+	}
+
+	if len(history) == 0 {
+		fmt.Println("Package", name, "not found in any revision.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tVERSION\tADDED\tREMOVED\tTAGS")
+	for _, h := range history {
+		removed := "-"
+		if h.Removed != nil {
+			removed = fmt.Sprintf("%v (%v)", h.Removed.Id, h.Removed.Timestamp())
+		}
+		tags := strings.Join(h.Tags, ", ")
+		if tags == "" {
+			tags = "<none>"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v (%v)\t%v\t%v\n", h.Package, h.Version, h.Added.Id, h.Added.Timestamp(), removed, tags)
+	}
+	w.Flush()
+	return nil
+}
+
+func (a *App) Search(pattern string, regex bool, repos []string, tags []string, output string) error {
+	if len(a.repositories) == 0 {
+		return failOutput("No repositories configured.")
+	}
+
+	match, err := nameMatcher(pattern, regex)
+	if err != nil {
+		return failOutput("search error: ", err)
+	}
+
+	var searched []*repository.Repository
+	if len(repos) == 0 {
+		searched = a.repositories
+	}
+	for _, repo := range repos {
+		r, ok := a.getRepoName(repo)
+		if !ok {
+			return failOutput("No configured repository", repo, "found.")
+		}
+		searched = append(searched, r)
+	}
+
+	var results []PackageSearchResult
+	for _, r := range searched {
+		matches, err := r.Search(match, tags...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: warning: search skipped: %v\n", r.Name, err)
+			continue
+		}
+
+		for _, m := range matches {
+			results = append(results, PackageSearchResult{
+				Name:    m.Name,
+				Arch:    m.Arch,
+				Version: m.Version.String(),
+				Repo:    r.Name,
+				Tags:    m.Tags,
+				evr:     m.Version,
+			})
+		}
+	}
+
+	// keep the configured order of the repositories per package version
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Arch != b.Arch {
+			return a.Arch < b.Arch
+		}
+		return repomd.CompareEVR(a.evr, b.evr) < 0
+	})
+
+	if output != OutputText {
+		if err := showSearchOutput(results, output); err != nil {
+			return failOutput("search error: ", err)
+		}
+		return nil
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No packages matching", pattern, "found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tARCH\tREPO\tTAGS")
+	for _, p := range results {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", p.Name, p.Version, p.Arch, p.Repo, strings.Join(p.Tags, ", "))
+	}
+	w.Flush()
+	return nil
+}
+
+func (a *App) Copy(srcRepo string, tagOrRev string, specs []string, dstRepo string, hardlink bool, withDeps bool) {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return
+	}
+
+	src, ok := a.getRepoName(srcRepo)
+	if !ok {
+		fmt.Println("No configured repository", srcRepo, "found.")
+		return
+	}
+
+	dst, ok := a.getRepoName(dstRepo)
+	if !ok {
+		fmt.Println("No configured repository", dstRepo, "found.")
+		return
+	}
+
+	n, err := src.Copy(tagOrRev, specs, dst, hardlink, withDeps)
+	if err != nil {
+		fmt.Println("copy error: ", err)
+		return
+	}
+
+	if n == 0 {
+		fmt.Println("No packages copied.")
+	}
+}
+
+func (a *App) CheckDeps(repo string, tagOrRev string, with []string) {
+	if len(a.repositories) == 0 {
+		fmt.Println("No repositories configured.")
+		return
+	}
+
+	r, ok := a.getRepoName(repo)
+	if !ok {
+		fmt.Println("No configured repository", repo, "found.")
+		return
+	}
+
+	if len(with) == 0 {
+		with = r.DependencyRepos
+	}
+
+	unresolved, err := a.checkDeps(r, tagOrRev, with)
+	if err != nil {
+		fmt.Println("check-deps error: ", err)
+		return
+	}
+
+	if len(unresolved) == 0 {
+		fmt.Println("No unresolved dependencies.")
+		return
+	}
+
+	showUnresolvedDeps(unresolved)
+}
+
+func (a *App) Server(port string) error {
+	s := server.NewServer(port, a.repositories, a.config.GlobalConfig.Server)
+	return s.Run()
+}
+
+func (a *App) isRepoName(repo string) bool {
+	for _, r := range a.repositories {
+		if repo == r.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *App) getRepoName(repo string) (*repository.Repository, bool) {
+	for i, r := range a.repositories {
+		if repo == r.Name {
+			return a.repositories[i], true
+		}
+	}
+	return nil, false
+}
+
+// getPackagesByRef returns the packages of a repo:tag or repo:revision
+// reference. The latest tag is used when only a repository name is given.
+func (a *App) getPackagesByRef(ref string) ([]repomd.RpmPackage, error) {
+	r, tagOrRev, err := a.getRepoByRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.Packages(tagOrRev)
+}
+
+// getRepoByRef returns the repository and the tag or revision of a
+// repo:tag or repo:revision reference. The latest tag is used when only a
+// repository name is given.
+func (a *App) getRepoByRef(ref string) (*repository.Repository, string, error) {
+	repo, tagOrRev := ref, config.DefaultLatestRevisionTag
+	if i := strings.LastIndex(ref, ":"); i >= 0 {
+		repo, tagOrRev = ref[:i], ref[i+1:]
+	}
+
+	r, ok := a.getRepoName(repo)
+	if !ok {
+		return nil, "", fmt.Errorf("no configured repository %s found", repo)
+	}
+
+	return r, tagOrRev, nil
+}
+
+// checkDeps checks the dependency closure of a repository tag or
+// revision together with the packages of the referenced repositories.
+func (a *App) checkDeps(r *repository.Repository, tagOrRev string, with []string) ([]repository.UnresolvedDep, error) {
+	var extra [][]repomd.RpmPackage
+	for _, ref := range with {
+		packages, err := a.getPackagesByRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		extra = append(extra, packages)
+	}
+
+	return r.CheckDeps(tagOrRev, extra...)
+}
+
+// nameMatcher returns a function matching package names against a glob
+// pattern or, when regex is true, an unanchored regular expression.
+func nameMatcher(pattern string, regex bool) (func(string) bool, error) {
+	if regex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob pattern %s", pattern)
+	}
+	return func(name string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}, nil
+}
+
+func (a *App) initContentPath() error {
+	if err := os.MkdirAll(a.config.GlobalConfig.ContentPath, 0700); err != nil {
+		return err
+	}
+	return nil
+}
+
+// The showRepo method prints detailed repository information to
+// standard ouptput of the specified repository when present.
+func (a *App) showRepo(repo string, labels []string, output string) error {
+	if r, ok := a.getRepoName(repo); ok {
+		if output != OutputText {
+			return showRevisionsOutput(r.Revisions, labels, output)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+		if r.HasRevisions() {
+			fmt.Fprintln(w, "REVISION\tCREATED\tPACKAGES\tSIZE\tUPSTREAM REVISION\tTAGS\tLABELS\tNOTE")
+			for _, v := range r.Revisions {
+				if !v.MatchesLabels(labels...) {
+					continue
+				}
+				tags := strings.Join(v.TagNames(), ", ")
+				if tags == "" {
+					tags = "<none>"
+				}
+				packages, size, upstream := "-", "-", "-"
+				if m := v.Manifest; m != nil {
+					packages = fmt.Sprintf("%v", m.Packages)
+					size = util.HumanBytes(m.TotalSize)
+					upstream = m.UpstreamRevision
+				}
+				labelList, note := "-", "-"
+				if l := v.Annotations.LabelStrings(); len(l) > 0 {
+					labelList = strings.Join(l, ", ")
+				}
+				if n := v.Annotations.LastNote(); n != "" {
+					note = n
+				}
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", v.Id, v.Timestamp(), packages, size, upstream, tags, labelList, note)
+			}
+		} else {
+			fmt.Fprintf(w, "No revisions available for repository %v\n.", repo)
+		}
+		return w.Flush()
+
+	} else {
+		return failOutput(fmt.Sprintf("Repository '%v' not found.", repo))
+	}
+}
+
+// showUnresolvedDeps prints the unresolved dependencies to standard output.
+func showUnresolvedDeps(unresolved []repository.UnresolvedDep) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tUNRESOLVED REQUIREMENT\tSTATUS")
+	for _, u := range unresolved {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", u.Package, u.Requires, u.Status)
+	}
+	return w.Flush()
+}
+
+// unresolvedCount returns the number of unresolved dependencies, leaving
+// out the requirements which couldn't be checked.
+func unresolvedCount(unresolved []repository.UnresolvedDep) int {
+	var n int
+	for _, u := range unresolved {
+		if u.Status == repository.DepUnresolved {
+			n++
+		}
+	}
+	return n
+}
+
+// The showRevision method prints the details and the manifest of a
+// repository revision to standard output.
+func (a *App) showRevision(repo string, tagOrRev string, output string) error {
+	r, ok := a.getRepoName(repo)
+	if !ok {
+		return failOutput(fmt.Sprintf("Repository '%v' not found.", repo))
+	}
+
+	rev, ok := r.RevisionByTagOrRevId(tagOrRev)
+	if !ok {
+		return failOutput(fmt.Sprintf("Tag or revision '%v' not found.", tagOrRev))
+	}
+
+	if output != OutputText {
+		return showRevisionOutput(rev, output)
+	}
+
+	tags := strings.Join(rev.TagNames(), ", ")
+	if tags == "" {
+		tags = "<none>"
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintf(w, "Revision:\t%v\n", rev.Id)
+	if rev.LegacyId != 0 {
+		fmt.Fprintf(w, "Legacy id:\t%v\n", rev.LegacyId)
+	}
+	fmt.Fprintf(w, "Created:\t%v\n", rev.Timestamp())
+	fmt.Fprintf(w, "Tags:\t%v\n", tags)
+
+	if m := rev.Manifest; m != nil {
+		fmt.Fprintf(w, "Source:\t%v\n", m.Source)
+		fmt.Fprintf(w, "Upstream revision:\t%v\n", m.UpstreamRevision)
+		if !m.UpstreamTimestamp.IsZero() {
+			fmt.Fprintf(w, "Upstream timestamp:\t%v\n", m.UpstreamTimestamp.Local().Format("2006-01-02 15:04:05"))
+		}
+		fmt.Fprintf(w, "Packages:\t%v\n", m.Packages)
+		fmt.Fprintf(w, "Total size:\t%v\n", util.HumanBytes(m.TotalSize))
+		fmt.Fprintf(w, "Downloaded:\t%v\n", util.HumanBytes(m.Downloaded))
+		fmt.Fprintf(w, "Sync duration:\t%v\n", m.SyncDuration)
+		fmt.Fprintf(w, "Synced at:\t%v\n", m.SyncedAt.Local().Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(w, "Synced with:\t%v\n", m.RrstVersion)
+		if len(m.Filters) > 0 {
+			fmt.Fprintf(w, "Filters:\t%v\n", strings.Join(m.Filters, ", "))
+		}
+	} else {
+		fmt.Fprintf(w, "Manifest:\t<none>\n")
+	}
+
+	if l := rev.Lock; l != nil {
+		fmt.Fprintf(w, "Locked:\t%v by %v\n", l.Time.Local().Format("2006-01-02 15:04:05"), l.User)
+	}
+
+	if a := rev.Annotations; a != nil {
+		if labels := a.LabelStrings(); len(labels) > 0 {
+			fmt.Fprintf(w, "Labels:\t%v\n", strings.Join(labels, ", "))
+		}
+		for _, n := range a.Notes {
+			fmt.Fprintf(w, "Note:\t%v %v: %v\n", n.Time.Local().Format("2006-01-02 15:04:05"), n.User, n.Text)
+		}
+	}
+
+	return w.Flush()
+}
+
+// showRevisionsToDelete prints the revisions and their tags which will
+// be deleted to standard output.
+func showRevisionsToDelete(revisions []*repository.Revision) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tCREATED\tTAGS")
+	for _, rev := range revisions {
+		tags := strings.Join(rev.TagNames(), ", ")
+		if tags == "" {
+			tags = "<none>"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\n", rev.Id, rev.Timestamp(), tags)
+	}
+	return w.Flush()
+}
+
+// confirm asks a yes/no question on standard input and returns true only
+// when answered with yes.
+func confirm(question string) bool {
+	fmt.Printf("%v [y/N] ", question)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// revisionOrNone returns the revision id as string or - when zero.
+func revisionOrNone(id int64) string {
+	if id == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%v", id)
+}
+
+// The showRepos method prints general repository information to
+// standard ouptput of all the configured repositories.
+func (a *App) showRepos(output string) error {
+	if output != OutputText {
+		return a.showReposOutput(output)
+	}
+
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
 	fmt.Fprintln(w, "ID\tREPOSITORY\tENABLED\t#REVISIONS\t#TAGS\tUPDATED")
 	for _, r := range a.repositories {
@@ -0,0 +1,451 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/catay/rrst/repository"
+	"github.com/catay/rrst/repository/repomd"
+	"gopkg.in/yaml.v2"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Output formats of the status, list, diff, history and search commands.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+	OutputCSV  = "csv"
+
+	// OutputMarkdown renders a change summary, only the diff command
+	// supports it.
+	OutputMarkdown = "markdown"
+)
+
+// OutputFormats lists the supported output formats.
+var OutputFormats = []string{OutputText, OutputJSON, OutputYAML, OutputCSV}
+
+// RepoStatus is the structured output of the status command without
+// repository name.
+type RepoStatus struct {
+	Id        int        `json:"id" yaml:"id"`
+	Name      string     `json:"name" yaml:"name"`
+	Enabled   bool       `json:"enabled" yaml:"enabled"`
+	Revisions int        `json:"revisions" yaml:"revisions"`
+	Tags      int        `json:"tags" yaml:"tags"`
+	Updated   *time.Time `json:"updated" yaml:"updated"`
+}
+
+// RevisionStatus is the structured output of the status command for the
+// revisions of a repository.
+type RevisionStatus struct {
+	Revision         int64             `json:"revision" yaml:"revision"`
+	Created          time.Time         `json:"created" yaml:"created"`
+	Packages         *int              `json:"packages" yaml:"packages"`
+	Size             *int64            `json:"size" yaml:"size"`
+	UpstreamRevision string            `json:"upstream_revision" yaml:"upstream_revision"`
+	Tags             []string          `json:"tags" yaml:"tags"`
+	Labels           map[string]string `json:"labels" yaml:"labels"`
+	Note             string            `json:"note" yaml:"note"`
+	Locked           bool              `json:"locked" yaml:"locked"`
+}
+
+// RevisionDetails is the structured output of the status command for a
+// single tag or revision. The manifest is nil for revisions without one.
+type RevisionDetails struct {
+	Revision int64                `json:"revision" yaml:"revision"`
+	LegacyId int64                `json:"legacy_id,omitempty" yaml:"legacy_id,omitempty"`
+	Created  time.Time            `json:"created" yaml:"created"`
+	Tags     []string             `json:"tags" yaml:"tags"`
+	Manifest *repository.Manifest `json:"manifest" yaml:"manifest"`
+	Lock     *RevisionLock        `json:"lock" yaml:"lock"`
+	Labels   map[string]string    `json:"labels" yaml:"labels"`
+	Notes    []repository.Note    `json:"notes" yaml:"notes"`
+}
+
+// RevisionLock tells when and by whom a revision got locked.
+type RevisionLock struct {
+	Time time.Time `json:"time" yaml:"time"`
+	User string    `json:"user" yaml:"user"`
+}
+
+// PackageList is the structured output of the list command. Versions
+// holds the versions of the package per tag or revision, sorted from old
+// to new and empty when the package is not present.
+type PackageList struct {
+	Package  string              `json:"package" yaml:"package"`
+	Name     string              `json:"name" yaml:"name"`
+	Arch     string              `json:"arch" yaml:"arch"`
+	Versions map[string][]string `json:"versions" yaml:"versions"`
+}
+
+// PackageChange is a package of the structured output of the diff
+// command.
+type PackageChange struct {
+	PackageList `yaml:",inline"`
+	Change      string                  `json:"change" yaml:"change"`
+	Changelog   []repomd.ChangelogEntry `json:"changelog,omitempty" yaml:"changelog,omitempty"`
+}
+
+// DiffResult is the structured output of the diff command.
+type DiffResult struct {
+	Refs     []string        `json:"refs" yaml:"refs"`
+	Packages []PackageChange `json:"packages" yaml:"packages"`
+	Summary  map[string]int  `json:"summary" yaml:"summary"`
+}
+
+// VersionHistory is a version of a package in the structured output of
+// the history command. Removed is nil while the version is present in the
+// latest revision.
+type VersionHistory struct {
+	Package string           `json:"package" yaml:"package"`
+	Name    string           `json:"name" yaml:"name"`
+	Arch    string           `json:"arch" yaml:"arch"`
+	Version string           `json:"version" yaml:"version"`
+	Added   HistoryRevision  `json:"added" yaml:"added"`
+	Removed *HistoryRevision `json:"removed" yaml:"removed"`
+	Tags    []string         `json:"tags" yaml:"tags"`
+}
+
+// HistoryRevision is the revision a version of a package got added or
+// removed in.
+type HistoryRevision struct {
+	Revision int64     `json:"revision" yaml:"revision"`
+	Created  time.Time `json:"created" yaml:"created"`
+}
+
+// PackageSearchResult is a version of a package in the structured output
+// of the search command, with the tags of the repository holding it.
+type PackageSearchResult struct {
+	Name    string   `json:"name" yaml:"name"`
+	Arch    string   `json:"arch" yaml:"arch"`
+	Version string   `json:"version" yaml:"version"`
+	Repo    string   `json:"repo" yaml:"repo"`
+	Tags    []string `json:"tags" yaml:"tags"`
+
+	evr repomd.EVR
+}
+
+// failOutput returns the failure of a command as error, whatever the
+// output format. It's written to standard error and the command exits
+// non-zero, so scripts can detect the failure and it's never taken for
+// output.
+func failOutput(a ...interface{}) error {
+	return errors.New(strings.TrimSuffix(fmt.Sprintln(a...), "\n"))
+}
+
+// writeOutput writes a value to standard output in the json or yaml
+// format. The csv format is written from the header and records.
+func writeOutput(format string, v interface{}, header []string, records [][]string) error {
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(v)
+	case OutputYAML:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	case OutputCSV:
+		w := csv.NewWriter(os.Stdout)
+		if err := w.Write(header); err != nil {
+			return err
+		}
+		if err := w.WriteAll(records); err != nil {
+			return err
+		}
+		return w.Error()
+	}
+	return fmt.Errorf("output format %s not supported", format)
+}
+
+// The showReposOutput method writes the general repository information of
+// all the configured repositories in a structured output format.
+func (a *App) showReposOutput(output string) error {
+	repos := []RepoStatus{}
+	var records [][]string
+
+	for _, r := range a.repositories {
+		s := RepoStatus{
+			Id:        r.Id,
+			Name:      r.Name,
+			Enabled:   r.Enabled,
+			Revisions: len(r.Revisions),
+			Tags:      len(r.Tags),
+		}
+
+		var updated string
+		if len(r.Revisions) > 0 {
+			created := r.Revisions[len(r.Revisions)-1].Created
+			s.Updated = &created
+			updated = formatTime(created)
+		}
+
+		repos = append(repos, s)
+		records = append(records, []string{
+			fmt.Sprint(s.Id), s.Name, fmt.Sprint(s.Enabled), fmt.Sprint(s.Revisions), fmt.Sprint(s.Tags), updated,
+		})
+	}
+
+	header := []string{"id", "name", "enabled", "revisions", "tags", "updated"}
+	return writeOutput(output, repos, header, records)
+}
+
+// showRevisionsOutput writes the revisions with all the labels in a
+// structured output format.
+func showRevisionsOutput(revisions []*repository.Revision, labels []string, output string) error {
+	list := []RevisionStatus{}
+	var records [][]string
+
+	for _, v := range revisions {
+		if !v.MatchesLabels(labels...) {
+			continue
+		}
+
+		s := RevisionStatus{
+			Revision: v.Id,
+			Created:  v.Created,
+			Tags:     append([]string{}, v.TagNames()...),
+			Labels:   labelsOrEmpty(v.Annotations),
+			Note:     v.Annotations.LastNote(),
+			Locked:   v.IsLocked(),
+		}
+		sort.Strings(s.Tags)
+
+		var packages, size string
+		if m := v.Manifest; m != nil {
+			s.Packages = &m.Packages
+			s.Size = &m.TotalSize
+			s.UpstreamRevision = m.UpstreamRevision
+			packages, size = fmt.Sprint(m.Packages), fmt.Sprint(m.TotalSize)
+		}
+
+		list = append(list, s)
+		records = append(records, []string{
+			fmt.Sprint(s.Revision), formatTime(s.Created), packages, size, s.UpstreamRevision,
+			strings.Join(s.Tags, " "), strings.Join(v.Annotations.LabelStrings(), " "), s.Note, fmt.Sprint(s.Locked),
+		})
+	}
+
+	header := []string{"revision", "created", "packages", "size", "upstream_revision", "tags", "labels", "note", "locked"}
+	return writeOutput(output, list, header, records)
+}
+
+// showRevisionOutput writes the details and the manifest of a revision in
+// a structured output format.
+func showRevisionOutput(rev *repository.Revision, output string) error {
+	d := RevisionDetails{
+		Revision: rev.Id,
+		LegacyId: rev.LegacyId,
+		Created:  rev.Created,
+		Tags:     append([]string{}, rev.TagNames()...),
+		Manifest: rev.Manifest,
+		Labels:   labelsOrEmpty(rev.Annotations),
+		Notes:    []repository.Note{},
+	}
+	sort.Strings(d.Tags)
+
+	if rev.Annotations != nil {
+		d.Notes = append(d.Notes, rev.Annotations.Notes...)
+	}
+
+	var lockTime, lockUser string
+	if l := rev.Lock; l != nil {
+		d.Lock = &RevisionLock{Time: l.Time, User: l.User}
+		lockTime, lockUser = formatTime(l.Time), l.User
+	}
+
+	record := []string{fmt.Sprint(d.Revision), formatTime(d.Created), strings.Join(d.Tags, " ")}
+	if m := d.Manifest; m != nil {
+		record = append(record, m.Source, m.UpstreamRevision, fmt.Sprint(m.Packages), fmt.Sprint(m.TotalSize),
+			fmt.Sprint(m.Downloaded), m.SyncDuration, formatTime(m.SyncedAt), m.RrstVersion)
+	} else {
+		record = append(record, "", "", "", "", "", "", "", "")
+	}
+	record = append(record, lockTime, lockUser, strings.Join(rev.Annotations.LabelStrings(), " "), rev.Annotations.LastNote())
+
+	header := []string{"revision", "created", "tags", "source", "upstream_revision", "packages", "total_size",
+		"downloaded", "sync_duration", "synced_at", "rrst_version", "locked_at", "locked_by", "labels", "note"}
+	return writeOutput(output, d, header, [][]string{record})
+}
+
+// showDiffOutput writes the package differences between tags or
+// revisions in a structured output format. The changelogs are left out
+// of the csv format.
+func showDiffOutput(refs []string, diffs []repository.PackageDiff, summary map[string]int, changelogs []repository.PackageChangelog, output string) error {
+	result := DiffResult{
+		Refs:     refs,
+		Packages: []PackageChange{},
+		Summary:  summary,
+	}
+
+	entries := make(map[string][]repomd.ChangelogEntry)
+	for _, c := range changelogs {
+		entries[c.Package] = c.Entries
+	}
+
+	var records [][]string
+	for _, d := range diffs {
+		p := newPackageList(d.Package, refs, d.Versions)
+		result.Packages = append(result.Packages, PackageChange{p, d.Change, entries[d.Package]})
+		records = append(records, append(p.csvRecord(refs), d.Change))
+	}
+
+	header := append(append([]string{"package", "name", "arch"}, refs...), "change")
+	return writeOutput(output, result, header, records)
+}
+
+// showHistoryOutput writes the history of the versions of a package in a
+// structured output format.
+func showHistoryOutput(history []repository.PackageHistory, output string) error {
+	list := []VersionHistory{}
+	var records [][]string
+
+	for _, h := range history {
+		v := VersionHistory{
+			Package: h.Package,
+			Name:    h.Name,
+			Arch:    h.Arch,
+			Version: h.Version.String(),
+			Added:   HistoryRevision{h.Added.Id, h.Added.Created},
+			Tags:    append([]string{}, h.Tags...),
+		}
+
+		var removed, removedCreated string
+		if h.Removed != nil {
+			v.Removed = &HistoryRevision{h.Removed.Id, h.Removed.Created}
+			removed, removedCreated = fmt.Sprint(h.Removed.Id), formatTime(h.Removed.Created)
+		}
+
+		list = append(list, v)
+		records = append(records, []string{
+			v.Package, v.Name, v.Arch, v.Version, fmt.Sprint(v.Added.Revision), formatTime(v.Added.Created),
+			removed, removedCreated, strings.Join(v.Tags, " "),
+		})
+	}
+
+	header := []string{"package", "name", "arch", "version", "added", "added_created", "removed", "removed_created", "tags"}
+	return writeOutput(output, list, header, records)
+}
+
+// showSearchOutput writes the package versions found by the search
+// command in a structured output format.
+func showSearchOutput(results []PackageSearchResult, output string) error {
+	var records [][]string
+	for _, p := range results {
+		records = append(records, []string{p.Name, p.Version, p.Arch, p.Repo, strings.Join(p.Tags, " ")})
+	}
+
+	if results == nil {
+		results = []PackageSearchResult{}
+	}
+
+	header := []string{"name", "version", "arch", "repo", "tags"}
+	return writeOutput(output, results, header, records)
+}
+
+// showDiffMarkdown writes the package differences between tags or
+// revisions as a Markdown change summary, followed by the changelogs of
+// the upgraded packages when given.
+func showDiffMarkdown(repo string, refs []string, diffs []repository.PackageDiff, summary map[string]int, changelogs []repository.PackageChangelog) {
+	fmt.Printf("## %v: %v\n\n", repo, strings.Join(refs, " -> "))
+	fmt.Printf("%v\n\n", summaryString(summary))
+
+	if len(diffs) > 0 {
+		fmt.Printf("| Package | %v | Change |\n", strings.Join(refs, " | "))
+		fmt.Printf("| --- |%v --- |\n", strings.Repeat(" --- |", len(refs)))
+		for _, d := range diffs {
+			var versions []string
+			for _, v := range d.Versions {
+				versions = append(versions, repository.VersionsString(v))
+			}
+			fmt.Printf("| %v | %v | %v |\n", d.Package, strings.Join(versions, " | "), d.Change)
+		}
+	}
+
+	for _, c := range changelogs {
+		fmt.Printf("\n### %v %v -> %v\n\n", c.Package, c.From, c.To)
+		if len(c.Entries) == 0 {
+			fmt.Println("No changelog entries.")
+			continue
+		}
+
+		fmt.Println("```")
+		for i, e := range c.Entries {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Println(e)
+		}
+		fmt.Println("```")
+	}
+}
+
+// summaryString returns the counts of the package changes.
+func summaryString(summary map[string]int) string {
+	return fmt.Sprintf("%v added, %v removed, %v upgraded, %v downgraded, %v changed",
+		summary[repository.PackageAdded], summary[repository.PackageRemoved],
+		summary[repository.PackageUpgraded], summary[repository.PackageDowngraded],
+		summary[repository.PackageChanged])
+}
+
+// formatTime returns the time in RFC 3339 format, an empty string for
+// the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// newPackageList returns the structured output of a package.arch with
+// its versions per tag or revision.
+func newPackageList(pkg string, refs []string, versions [][]repomd.EVR) PackageList {
+	p := PackageList{
+		Package:  pkg,
+		Name:     pkg,
+		Versions: make(map[string][]string),
+	}
+
+	if i := strings.LastIndex(pkg, "."); i >= 0 {
+		p.Name, p.Arch = pkg[:i], pkg[i+1:]
+	}
+
+	for i, ref := range refs {
+		p.Versions[ref] = []string{}
+		for _, e := range versions[i] {
+			p.Versions[ref] = append(p.Versions[ref], e.String())
+		}
+	}
+	return p
+}
+
+// csvRecord returns the csv record of a package.arch with a column per
+// tag or revision, holding the space separated versions.
+func (p PackageList) csvRecord(refs []string) []string {
+	record := []string{p.Package, p.Name, p.Arch}
+	for _, ref := range refs {
+		record = append(record, strings.Join(p.Versions[ref], " "))
+	}
+	return record
+}
+
+// labelsOrEmpty returns the labels of annotations, an empty map when
+// there are none.
+func labelsOrEmpty(a *repository.Annotations) map[string]string {
+	labels := make(map[string]string)
+	if a != nil {
+		for k, v := range a.Labels {
+			labels[k] = v
+		}
+	}
+	return labels
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"github.com/catay/rrst/cmd/app"
 	"github.com/catay/rrst/version"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -19,24 +20,102 @@ type Cli struct {
 	cmdList              *kingpin.CmdClause
 	cmdUpdate            *kingpin.CmdClause
 	cmdTag               *kingpin.CmdClause
+	cmdTagCreate         *kingpin.CmdClause
+	cmdTagDelete         *kingpin.CmdClause
+	cmdTagRename         *kingpin.CmdClause
+	cmdTagHistory        *kingpin.CmdClause
+	cmdTagRollback       *kingpin.CmdClause
 	cmdDelete            *kingpin.CmdClause
 	cmdDiff              *kingpin.CmdClause
+	cmdHistory           *kingpin.CmdClause
+	cmdSearch            *kingpin.CmdClause
+	cmdCopy              *kingpin.CmdClause
+	cmdCheckDeps         *kingpin.CmdClause
+	cmdPromote           *kingpin.CmdClause
+	cmdAutoTag           *kingpin.CmdClause
+	cmdAnnotate          *kingpin.CmdClause
+	cmdLock              *kingpin.CmdClause
+	cmdUnlock            *kingpin.CmdClause
+	cmdVerify            *kingpin.CmdClause
+	cmdFsck              *kingpin.CmdClause
+	cmdExport            *kingpin.CmdClause
+	cmdImport            *kingpin.CmdClause
 	cmdServer            *kingpin.CmdClause
 	cmdTagForceFlag      *bool
+	cmdTagCheckDepsFlag  *bool
 	cmdDeleteForceFlag   *bool
 	cmdCreateRepoArg     *string
 	cmdStatusRepoArg     *string
+	cmdStatusTagOrRevArg *string
+	cmdStatusLabelFlag   *[]string
+	cmdStatusOutputFlag  *string
 	cmdListRepoArg       *string
 	cmdListTagsOrRevsArg *[]string
+	cmdListOutputFlag    *string
 	cmdUpdateRepoArg     *string
 	cmdUpdateRevArg      *int64
 	cmdTagRepoArg        *string
 	cmdTagTagArg         *string
 	cmdTagRevArg         *int64
+	cmdTagDeleteRepoArg  *string
+	cmdTagDeleteTagArg   *string
+	cmdTagRenameRepoArg  *string
+	cmdTagRenameOldArg   *string
+	cmdTagRenameNewArg   *string
+	cmdTagHistoryRepoArg *string
+	cmdTagHistoryTagArg  *string
+	cmdTagRollbackRepo   *string
+	cmdTagRollbackTag    *string
+	cmdTagRollbackSteps  *int
 	cmdDeleteRepoArg     *string
 	cmdDeleteRevArg      *int64
 	cmdDiffRepoArg       *string
 	cmdDiffTagsOrRevsArg *[]string
+	cmdDiffOutputFlag    *string
+	cmdDiffChangelogFlag *bool
+	cmdHistoryRepoArg    *string
+	cmdHistoryPackageArg *string
+	cmdHistoryArchFlag   *string
+	cmdHistoryOutputFlag *string
+	cmdSearchPatternArg  *string
+	cmdSearchRegexFlag   *bool
+	cmdSearchRepoFlag    *[]string
+	cmdSearchTagFlag     *[]string
+	cmdSearchOutputFlag  *string
+	cmdCopySrcRepoArg    *string
+	cmdCopyTagOrRevArg   *string
+	cmdCopySpecsDstArg   *[]string
+	cmdCopyHardlinkFlag  *bool
+	cmdCopyWithDepsFlag  *bool
+	cmdCheckDepsRepoArg  *string
+	cmdCheckDepsRefArg   *string
+	cmdCheckDepsWithFlag *[]string
+	cmdPromoteRepoArg    *string
+	cmdPromoteStageArg   *string
+	cmdPromoteForceFlag  *bool
+	cmdAutoTagRepoArg    *string
+	cmdAnnotateRepoArg   *string
+	cmdAnnotateRefArg    *string
+	cmdAnnotateTextArg   *[]string
+	cmdLockRepoArg       *string
+	cmdLockRefArg        *string
+	cmdLockVerifyFlag    *bool
+	cmdUnlockRepoArg     *string
+	cmdUnlockRefArg      *string
+	cmdVerifyRepoArg     *string
+	cmdVerifyRefArg      *string
+	cmdVerifyRepairFlag  *bool
+	cmdFsckRepoArg       *string
+	cmdFsckFixFlag       *bool
+	cmdExportRepoArg     *string
+	cmdExportRefArg      *string
+	cmdExportOutputFlag  *string
+	cmdExportSinceFlag   *string
+	cmdExportKeyFlag     *string
+	cmdImportBundleArg   *string
+	cmdImportRepoFlag    *string
+	cmdImportTagFlag     *bool
+	cmdImportSkipVerify  *bool
 	cmdServerPort        *string
 }
 
@@ -55,27 +134,122 @@ func NewCli() *Cli {
 	c.cmdTag = c.Command("tag", "Tag repository revisions.")
 	c.cmdDelete = c.Command("delete", "Delete repository revisions and tags.")
 	c.cmdDiff = c.Command("diff", "Show package differences between repository tags.")
+	c.cmdHistory = c.Command("history", "Show in which revisions and tags the versions of a package are present.")
+	c.cmdSearch = c.Command("search", "Search the packages of all repositories by name.")
+	c.cmdCopy = c.Command("copy", "Copy packages from a repository tag or revision into a local repository.")
+	c.cmdCheckDeps = c.Command("check-deps", "Report the unresolvable dependencies of a repository tag or revision.")
+	c.cmdPromote = c.Command("promote", "Promote a revision to the next stage of the repository pipeline.")
+	c.cmdAutoTag = c.Command("autotag", "Apply the automatic tagging rules of repositories.")
+	c.cmdAnnotate = c.Command("annotate", "Add notes and labels to a repository revision.")
+	c.cmdLock = c.Command("lock", "Lock a repository revision or verify the locked revisions.")
+	c.cmdUnlock = c.Command("unlock", "Unlock a repository revision.")
+	c.cmdVerify = c.Command("verify", "Verify the metadata and packages of repository revisions.")
+	c.cmdFsck = c.Command("fsck", "Check the content path of repositories for corrupt state.")
+	c.cmdExport = c.Command("export", "Export a repository revision to a signed bundle.")
+	c.cmdImport = c.Command("import", "Import a repository revision from a bundle.")
 	c.cmdServer = c.Command("server", "HTTP server serving repositories.")
 
 	c.cmdCreateRepoArg = c.cmdCreate.Arg("repo name", "Repository name.").String()
 	c.cmdStatusRepoArg = c.cmdStatus.Arg("repo name", "Repository name.").String()
+	c.cmdStatusTagOrRevArg = c.cmdStatus.Arg("tag|revision", "Show the details and manifest of a tag or revision.").String()
+	c.cmdStatusLabelFlag = c.cmdStatus.Flag("label", "Only show revisions with the key=value or key label, can be repeated.").Short('l').Strings()
+	c.cmdStatusOutputFlag = c.cmdStatus.Flag("output", "Output format: text, json, yaml or csv.").Short('o').Default(app.OutputText).Enum(app.OutputFormats...)
 	c.cmdListRepoArg = c.cmdList.Arg("repo name", "Repository name.").Required().String()
 	c.cmdListTagsOrRevsArg = c.cmdList.Arg("tag|revision", "Show the packages matching a specific set of tags or revisions.").Strings()
+	c.cmdListOutputFlag = c.cmdList.Flag("output", "Output format: text, json, yaml or csv.").Short('o').Default(app.OutputText).Enum(app.OutputFormats...)
 
 	c.cmdUpdateRepoArg = c.cmdUpdate.Arg("repo name", "Repository to update.").String()
 	c.cmdUpdateRevArg = c.cmdUpdate.Arg("revision", "Revision to update.").Int64()
 
-	c.cmdTagRepoArg = c.cmdTag.Arg("repo name", "Repository name.").Required().String()
-	c.cmdTagTagArg = c.cmdTag.Arg("tag name", "Tag name.").Required().String()
-	c.cmdTagRevArg = c.cmdTag.Arg("revision", "Revision to tag.").Required().Int64()
-	c.cmdTagForceFlag = c.cmdTag.Flag("force", "Force tag creation. Default is false.").Short('f').Bool()
+	c.cmdTagCreate = c.cmdTag.Command("create", "Create or move a tag to a revision. This is the default tag command.").Default()
+	c.cmdTagRepoArg = c.cmdTagCreate.Arg("repo name", "Repository name.").Required().String()
+	c.cmdTagTagArg = c.cmdTagCreate.Arg("tag name", "Tag name.").Required().String()
+	c.cmdTagRevArg = c.cmdTagCreate.Arg("revision", "Revision to tag.").Required().Int64()
+	c.cmdTagForceFlag = c.cmdTagCreate.Flag("force", "Force tag creation. Default is false.").Short('f').Bool()
+	c.cmdTagCheckDepsFlag = c.cmdTagCreate.Flag("check-deps", "Refuse to tag a revision with unresolvable dependencies.").Bool()
+
+	c.cmdTagDelete = c.cmdTag.Command("delete", "Delete a tag.")
+	c.cmdTagDeleteRepoArg = c.cmdTagDelete.Arg("repo name", "Repository name.").Required().String()
+	c.cmdTagDeleteTagArg = c.cmdTagDelete.Arg("tag name", "Tag to delete.").Required().String()
+
+	c.cmdTagRename = c.cmdTag.Command("rename", "Rename a tag.")
+	c.cmdTagRenameRepoArg = c.cmdTagRename.Arg("repo name", "Repository name.").Required().String()
+	c.cmdTagRenameOldArg = c.cmdTagRename.Arg("old tag name", "Tag to rename.").Required().String()
+	c.cmdTagRenameNewArg = c.cmdTagRename.Arg("new tag name", "New tag name.").Required().String()
+
+	c.cmdTagHistory = c.cmdTag.Command("history", "Show the recorded changes of a tag.")
+	c.cmdTagHistoryRepoArg = c.cmdTagHistory.Arg("repo name", "Repository name.").Required().String()
+	c.cmdTagHistoryTagArg = c.cmdTagHistory.Arg("tag name", "Tag name. Shows the changes of all tags when omitted.").String()
+
+	c.cmdTagRollback = c.cmdTag.Command("rollback", "Restore the revision a tag pointed to before its last changes.")
+	c.cmdTagRollbackRepo = c.cmdTagRollback.Arg("repo name", "Repository name.").Required().String()
+	c.cmdTagRollbackTag = c.cmdTagRollback.Arg("tag name", "Tag to roll back.").Required().String()
+	c.cmdTagRollbackSteps = c.cmdTagRollback.Arg("steps", "Number of tag changes to roll back.").Default("1").Int()
 
 	c.cmdDeleteRepoArg = c.cmdDelete.Arg("repo name", "Repository name.").Required().String()
-	c.cmdDeleteRevArg = c.cmdDelete.Arg("revision", "Revision to delete.").Int64()
+	c.cmdDeleteRevArg = c.cmdDelete.Arg("revision", "Revision to delete, all revisions when omitted.").Int64()
 	c.cmdDeleteForceFlag = c.cmdDelete.Flag("force", "Force deletion, never prompt. Default is false.").Short('f').Bool()
 
-	c.cmdDiffRepoArg = c.cmdDiff.Arg("repo name", "Repository name.").Required().String()
-	c.cmdDiffTagsOrRevsArg = c.cmdDiff.Arg("tag|revision", "Compare package versions between repository tags or revisions.").Required().Strings()
+	c.cmdDiffRepoArg = c.cmdDiff.Arg("repo name", "Repository name or repo:tag reference.").Required().String()
+	c.cmdDiffTagsOrRevsArg = c.cmdDiff.Arg("tag|revision", "Compare package versions between repository tags or revisions, use repo:tag for other repositories.").Required().Strings()
+	c.cmdDiffOutputFlag = c.cmdDiff.Flag("output", "Output format: text, json, yaml, csv or markdown.").Short('o').Default(app.OutputText).Enum(append(app.OutputFormats, app.OutputMarkdown)...)
+	c.cmdDiffChangelogFlag = c.cmdDiff.Flag("changelog", "Show the changelog entries of the packages upgraded between two tags or revisions.").Bool()
+
+	c.cmdHistoryRepoArg = c.cmdHistory.Arg("repo name", "Repository name.").Required().String()
+	c.cmdHistoryPackageArg = c.cmdHistory.Arg("package name", "Package name without version and architecture.").Required().String()
+	c.cmdHistoryArchFlag = c.cmdHistory.Flag("arch", "Only show the package for this architecture.").String()
+	c.cmdHistoryOutputFlag = c.cmdHistory.Flag("output", "Output format: text, json, yaml or csv.").Short('o').Default(app.OutputText).Enum(app.OutputFormats...)
+
+	c.cmdSearchPatternArg = c.cmdSearch.Arg("pattern", "Glob pattern matching the package name, or a regular expression with --regex.").Required().String()
+	c.cmdSearchRegexFlag = c.cmdSearch.Flag("regex", "Match the package name with a regular expression instead of a glob pattern.").Short('E').Bool()
+	c.cmdSearchRepoFlag = c.cmdSearch.Flag("repo", "Only search this repository, can be repeated.").Strings()
+	c.cmdSearchTagFlag = c.cmdSearch.Flag("tag", "Only search the revisions with this tag, can be repeated.").Strings()
+	c.cmdSearchOutputFlag = c.cmdSearch.Flag("output", "Output format: text, json, yaml or csv.").Short('o').Default(app.OutputText).Enum(app.OutputFormats...)
+
+	c.cmdCopySrcRepoArg = c.cmdCopy.Arg("src repo name", "Source repository name.").Required().String()
+	c.cmdCopyTagOrRevArg = c.cmdCopy.Arg("tag|revision", "Source tag or revision.").Required().String()
+	c.cmdCopySpecsDstArg = c.cmdCopy.Arg("package spec... dst repo name", "Package name patterns to copy, followed by the destination repository name.").Required().Strings()
+	c.cmdCopyHardlinkFlag = c.cmdCopy.Flag("hardlink", "Hard link the package files instead of copying them when possible.").Short('l').Bool()
+	c.cmdCopyWithDepsFlag = c.cmdCopy.Flag("with-deps", "Include the dependencies missing in the destination repository.").Short('d').Bool()
+
+	c.cmdCheckDepsRepoArg = c.cmdCheckDeps.Arg("repo name", "Repository name.").Required().String()
+	c.cmdCheckDepsRefArg = c.cmdCheckDeps.Arg("tag|revision", "Tag or revision to check.").Required().String()
+	c.cmdCheckDepsWithFlag = c.cmdCheckDeps.Flag("with", "Also resolve against repo:tag, can be repeated. Defaults to the configured dependency_repos.").Strings()
+
+	c.cmdPromoteRepoArg = c.cmdPromote.Arg("repo name", "Repository name.").Required().String()
+	c.cmdPromoteStageArg = c.cmdPromote.Arg("stage", "Pipeline stage to promote to.").Required().String()
+	c.cmdPromoteForceFlag = c.cmdPromote.Flag("force", "Skip missing stages and don't enforce the pipeline rules. Default is false.").Short('f').Bool()
+
+	c.cmdAutoTagRepoArg = c.cmdAutoTag.Arg("repo name", "Repository name. Applies the rules of all repositories when omitted.").String()
+
+	c.cmdAnnotateRepoArg = c.cmdAnnotate.Arg("repo name", "Repository name.").Required().String()
+	c.cmdAnnotateRefArg = c.cmdAnnotate.Arg("tag|revision", "Tag or revision to annotate.").Required().String()
+	c.cmdAnnotateTextArg = c.cmdAnnotate.Arg("note|key=value", "Note text and key=value labels, an empty value removes the label.").Required().Strings()
+
+	c.cmdLockRepoArg = c.cmdLock.Arg("repo name", "Repository name.").Required().String()
+	c.cmdLockRefArg = c.cmdLock.Arg("tag|revision", "Tag or revision to lock. Verifies all locked revisions when omitted with --verify.").String()
+	c.cmdLockVerifyFlag = c.cmdLock.Flag("verify", "Verify the content of locked revisions didn't change since they were locked.").Bool()
+
+	c.cmdUnlockRepoArg = c.cmdUnlock.Arg("repo name", "Repository name.").Required().String()
+	c.cmdUnlockRefArg = c.cmdUnlock.Arg("tag|revision", "Tag or revision to unlock.").Required().String()
+
+	c.cmdVerifyRepoArg = c.cmdVerify.Arg("repo name", "Repository name.").Required().String()
+	c.cmdVerifyRefArg = c.cmdVerify.Arg("tag|revision", "Tag or revision to verify. Verifies all revisions and reports extra package files when omitted.").String()
+	c.cmdVerifyRepairFlag = c.cmdVerify.Flag("repair", "Download missing and corrupt files again from upstream.").Bool()
+
+	c.cmdFsckRepoArg = c.cmdFsck.Arg("repo name", "Repository name.").String()
+	c.cmdFsckFixFlag = c.cmdFsck.Flag("fix", "Apply the safe fixes. Don't use while repositories are updated.").Bool()
+
+	c.cmdExportRepoArg = c.cmdExport.Arg("repo name", "Repository name.").Required().String()
+	c.cmdExportRefArg = c.cmdExport.Arg("tag|revision", "Tag or revision to export.").Required().String()
+	c.cmdExportOutputFlag = c.cmdExport.Flag("output", "Bundle file name, compressed when ending with .zst or .gz. Default is <repo name>-<revision>.tar.zst.").Short('o').String()
+	c.cmdExportSinceFlag = c.cmdExport.Flag("since", "Leave out the packages of a previously exported tag or revision.").String()
+	c.cmdExportKeyFlag = c.cmdExport.Flag("key", "Signing key. Defaults to the configured bundle signing_key.").String()
+
+	c.cmdImportBundleArg = c.cmdImport.Arg("bundle", "Bundle file to import.").Required().String()
+	c.cmdImportRepoFlag = c.cmdImport.Flag("repo", "Repository to import into. Defaults to the repository of the bundle.").String()
+	c.cmdImportTagFlag = c.cmdImport.Flag("tag", "Also create the tag the revision was exported with.").Short('t').Bool()
+	c.cmdImportSkipVerify = c.cmdImport.Flag("skip-verify", "Don't verify the bundle signature. Default is false.").Bool()
 
 	c.cmdServerPort = c.cmdServer.Flag("port", "Port number to listen on.").Short('p').Default(app.DefaultPort).String()
 	return c
@@ -98,12 +272,46 @@ func (c *Cli) Run() error {
 		err = c.listCli()
 	case "update":
 		err = c.updateCli()
-	case "tag":
+	case "tag create":
 		err = c.tagCli()
+	case "tag delete":
+		err = c.tagDeleteCli()
+	case "tag rename":
+		err = c.tagRenameCli()
+	case "tag history":
+		err = c.tagHistoryCli()
+	case "tag rollback":
+		err = c.tagRollbackCli()
 	case "diff":
 		err = c.diffCli()
+	case "history":
+		err = c.historyCli()
+	case "search":
+		err = c.searchCli()
 	case "delete":
 		err = c.deleteCli()
+	case "copy":
+		err = c.copyCli()
+	case "check-deps":
+		err = c.checkDepsCli()
+	case "promote":
+		err = c.promoteCli()
+	case "autotag":
+		err = c.autoTagCli()
+	case "annotate":
+		err = c.annotateCli()
+	case "lock":
+		err = c.lockCli()
+	case "unlock":
+		err = c.unlockCli()
+	case "verify":
+		err = c.verifyCli()
+	case "fsck":
+		err = c.fsckCli()
+	case "export":
+		err = c.exportCli()
+	case "import":
+		err = c.importCli()
 	case "server":
 		err = c.serverCli()
 	}
@@ -117,13 +325,11 @@ func (c *Cli) createCli() error {
 }
 
 func (c *Cli) statusCli() error {
-	c.app.Status(*c.cmdStatusRepoArg)
-	return nil
+	return c.app.Status(*c.cmdStatusRepoArg, *c.cmdStatusTagOrRevArg, *c.cmdStatusLabelFlag, *c.cmdStatusOutputFlag)
 }
 
 func (c *Cli) listCli() error {
-	c.app.List(*c.cmdListRepoArg, *c.cmdListTagsOrRevsArg...)
-	return nil
+	return c.app.List(*c.cmdListRepoArg, *c.cmdListOutputFlag, *c.cmdListTagsOrRevsArg...)
 }
 
 func (c *Cli) updateCli() error {
@@ -132,20 +338,106 @@ func (c *Cli) updateCli() error {
 }
 
 func (c *Cli) tagCli() error {
-	c.app.Tag(*c.cmdTagRepoArg, *c.cmdTagTagArg, *c.cmdTagRevArg, *c.cmdTagForceFlag)
+	c.app.Tag(*c.cmdTagRepoArg, *c.cmdTagTagArg, *c.cmdTagRevArg, *c.cmdTagForceFlag, *c.cmdTagCheckDepsFlag)
 	return nil
 }
 
-func (c *Cli) diffCli() error {
-	c.app.Diff(*c.cmdDiffRepoArg, *c.cmdDiffTagsOrRevsArg...)
+func (c *Cli) tagDeleteCli() error {
+	c.app.DeleteTag(*c.cmdTagDeleteRepoArg, *c.cmdTagDeleteTagArg)
+	return nil
+}
+
+func (c *Cli) tagRenameCli() error {
+	c.app.RenameTag(*c.cmdTagRenameRepoArg, *c.cmdTagRenameOldArg, *c.cmdTagRenameNewArg)
+	return nil
+}
+
+func (c *Cli) tagHistoryCli() error {
+	c.app.TagHistory(*c.cmdTagHistoryRepoArg, *c.cmdTagHistoryTagArg)
 	return nil
 }
 
+func (c *Cli) tagRollbackCli() error {
+	c.app.RollbackTag(*c.cmdTagRollbackRepo, *c.cmdTagRollbackTag, *c.cmdTagRollbackSteps)
+	return nil
+}
+
+func (c *Cli) diffCli() error {
+	return c.app.Diff(*c.cmdDiffRepoArg, *c.cmdDiffOutputFlag, *c.cmdDiffChangelogFlag, *c.cmdDiffTagsOrRevsArg...)
+}
+
+func (c *Cli) historyCli() error {
+	return c.app.History(*c.cmdHistoryRepoArg, *c.cmdHistoryPackageArg, *c.cmdHistoryArchFlag, *c.cmdHistoryOutputFlag)
+}
+
+func (c *Cli) searchCli() error {
+	return c.app.Search(*c.cmdSearchPatternArg, *c.cmdSearchRegexFlag, *c.cmdSearchRepoFlag, *c.cmdSearchTagFlag, *c.cmdSearchOutputFlag)
+}
+
 func (c *Cli) deleteCli() error {
 	c.app.Delete(*c.cmdDeleteRepoArg, *c.cmdDeleteRevArg, *c.cmdDeleteForceFlag)
 	return nil
 }
 
+func (c *Cli) copyCli() error {
+	args := *c.cmdCopySpecsDstArg
+	if len(args) < 2 {
+		return fmt.Errorf("copy requires at least one package spec and a destination repository")
+	}
+
+	c.app.Copy(*c.cmdCopySrcRepoArg, *c.cmdCopyTagOrRevArg, args[:len(args)-1], args[len(args)-1], *c.cmdCopyHardlinkFlag, *c.cmdCopyWithDepsFlag)
+	return nil
+}
+
+func (c *Cli) checkDepsCli() error {
+	c.app.CheckDeps(*c.cmdCheckDepsRepoArg, *c.cmdCheckDepsRefArg, *c.cmdCheckDepsWithFlag)
+	return nil
+}
+
+func (c *Cli) promoteCli() error {
+	c.app.Promote(*c.cmdPromoteRepoArg, *c.cmdPromoteStageArg, *c.cmdPromoteForceFlag)
+	return nil
+}
+
+func (c *Cli) autoTagCli() error {
+	c.app.AutoTag(*c.cmdAutoTagRepoArg)
+	return nil
+}
+
+func (c *Cli) annotateCli() error {
+	c.app.Annotate(*c.cmdAnnotateRepoArg, *c.cmdAnnotateRefArg, *c.cmdAnnotateTextArg)
+	return nil
+}
+
+func (c *Cli) lockCli() error {
+	if *c.cmdLockVerifyFlag {
+		return c.app.VerifyLocks(*c.cmdLockRepoArg, *c.cmdLockRefArg)
+	}
+	c.app.Lock(*c.cmdLockRepoArg, *c.cmdLockRefArg)
+	return nil
+}
+
+func (c *Cli) unlockCli() error {
+	c.app.Unlock(*c.cmdUnlockRepoArg, *c.cmdUnlockRefArg)
+	return nil
+}
+
+func (c *Cli) verifyCli() error {
+	return c.app.Verify(*c.cmdVerifyRepoArg, *c.cmdVerifyRefArg, *c.cmdVerifyRepairFlag)
+}
+
+func (c *Cli) fsckCli() error {
+	return c.app.Fsck(*c.cmdFsckRepoArg, *c.cmdFsckFixFlag)
+}
+
+func (c *Cli) exportCli() error {
+	return c.app.Export(*c.cmdExportRepoArg, *c.cmdExportRefArg, *c.cmdExportSinceFlag, *c.cmdExportOutputFlag, *c.cmdExportKeyFlag)
+}
+
+func (c *Cli) importCli() error {
+	return c.app.Import(*c.cmdImportBundleArg, *c.cmdImportRepoFlag, *c.cmdImportTagFlag, *c.cmdImportSkipVerify)
+}
+
 func (c *Cli) serverCli() error {
 	return c.app.Server(*c.cmdServerPort)
 }
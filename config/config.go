@@ -7,11 +7,12 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 )
 
 // Default configuration values.
 const (
-	ValidTagsRegex                = "^[a-z|A-Z|0-9|_]+$"
+	ValidTagsRegex                = "^[a-zA-Z0-9_][a-zA-Z0-9_.-]*(/[a-zA-Z0-9_][a-zA-Z0-9_.-]*)*$"
 	DefaultConfigPath             = "/etc/rrst/config.yaml"
 	DefaultServerPort             = "4280"
 	DefaultContentPath            = "~/.rrst/content"
@@ -21,6 +22,7 @@ const (
 	DefaultContentTmpPathSuffix   = "tmp"
 	DefaultContentTagsPathSuffix  = "tags"
 	DefaultLatestRevisionTag      = "latest"
+	RrstRepositoryType            = "rrst"
 	ContentPathEnv                = "RRST_CONTENT_PATH"
 	CreateRepoOpts                = "-o"
 )
@@ -39,26 +41,61 @@ type Config struct {
 
 // GlobalConfig contains the global configuration settings.
 type GlobalConfig struct {
-	ContentPath        string      `yaml:"content_path"`
-	Providers          []*Provider `yaml:"providers"`
-	MaxRevisionsToKeep int         `yaml:"max_revs_to_keep"`
+	ContentPath        string       `yaml:"content_path"`
+	Providers          []*Provider  `yaml:"providers"`
+	Pipelines          []*Pipeline  `yaml:"pipelines"`
+	MaxRevisionsToKeep int          `yaml:"max_revs_to_keep"`
+	Server             ServerConfig `yaml:"server"`
+	Bundle             BundleConfig `yaml:"bundle"`
+}
+
+// ServerConfig contains the settings of the built-in web server.
+//
+// Uploads into local repositories are only accepted when at least one
+// user is configured.
+type ServerConfig struct {
+	Users []*User `yaml:"users"`
+}
+
+// BundleConfig contains the keys to sign and verify export bundles.
+//
+// SigningKey is the path of the PEM encoded PKCS #8 ed25519 private key
+// used by the export command. TrustedKeys lists the paths of the PEM
+// encoded ed25519 public keys the import command accepts bundles from.
+type BundleConfig struct {
+	SigningKey  string   `yaml:"signing_key"`
+	TrustedKeys []string `yaml:"trusted_keys"`
+}
+
+// User contains the credentials of a user allowed to upload packages.
+// The password can reference an environment variable like ${RRST_PASS}.
+type User struct {
+	Name     string `yaml:"name"`
+	Password string `yaml:"password"`
 }
 
 // RepositoryConfig contains the per repository configuration settings.
 type RepositoryConfig struct {
-	Id                 int    `yaml:"id"`
-	Name               string `yaml:"name"`
-	RType              string `yaml:"type"`
-	ProviderId         string `yaml:"provider_id"`
-	RemoteURI          string `yaml:"remote_uri"`
-	ContentSuffixPath  string `yaml:"content_suffix_path"`
-	MaxRevisionsToKeep int    `yaml:"max_tags_to_keep"`
-	Enabled            bool   `yaml:"enabled"`
+	Id                 int        `yaml:"id"`
+	Name               string     `yaml:"name"`
+	RType              string     `yaml:"type"`
+	ProviderId         string     `yaml:"provider_id"`
+	PipelineId         string     `yaml:"pipeline_id"`
+	RemoteURI          string     `yaml:"remote_uri"`
+	ContentSuffixPath  string     `yaml:"content_suffix_path"`
+	MaxRevisionsToKeep int        `yaml:"max_tags_to_keep"`
+	Enabled            bool       `yaml:"enabled"`
+	DependencyRepos    []string   `yaml:"dependency_repos"`
+	RequireClosure     bool       `yaml:"require_closure"`
+	TagRules           []*TagRule `yaml:"tag_rules"`
+	ProtectedTags      []string   `yaml:"protected_tags"`
+	UpstreamTags       []string   `yaml:"upstream_tags"`
 	ContentFilesPath   string
 	ContentMDPath      string
 	ContentTagsPath    string
 	ContentTmpPath     string
 	Provider           *Provider
+	Pipeline           *Pipeline
 }
 
 // Provider contains provider specific configuration settings.
@@ -78,6 +115,45 @@ type Provider struct {
 	} `yaml:"variables"`
 }
 
+// Pipeline contains a promotion pipeline definition.
+//
+// Id has to contain a free to choose name to which a repo can map too.
+// Stages lists the stage tags in promotion order, for example dev, test,
+// acc and prod. The first stage is promoted from the latest tag.
+// MinSoak is the minimum time a revision has to stay in the previous
+// stage before it can be promoted, for example 72h.
+type Pipeline struct {
+	Id      string        `yaml:"id"`
+	Stages  []string      `yaml:"stages"`
+	MinSoak time.Duration `yaml:"min_soak"`
+}
+
+// StageIndex returns the position of a stage in the pipeline.
+// The bool is false when the stage is not part of the pipeline.
+func (p *Pipeline) StageIndex(stage string) (int, bool) {
+	for i, s := range p.Stages {
+		if s == stage {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// TagRule contains an automatic tagging rule of a repository.
+//
+// Tag is the tag name to set and can contain the YYYY, MM and DD date
+// placeholders, which are replaced with the creation date of the
+// revision. Follow is the tag the rule follows, latest by default.
+// Delay makes the tag follow with a delay, it gets the revision the
+// followed tag pointed to at that time. Once only sets the tag when it
+// doesn't exist yet, for example to tag the first revision of a month.
+type TagRule struct {
+	Tag    string        `yaml:"tag"`
+	Follow string        `yaml:"follow"`
+	Delay  time.Duration `yaml:"delay"`
+	Once   bool          `yaml:"once"`
+}
+
 // NewConfig loads the configuration from a YAML file and returns it.
 // The config will be nil when an error is encountered.
 func NewConfig(configFile string) (c *Config, err error) {
@@ -104,6 +180,10 @@ func NewConfig(configFile string) (c *Config, err error) {
 		c.GlobalConfig.Providers[i].SetEnvVars()
 	}
 
+	for i, _ := range c.GlobalConfig.Server.Users {
+		c.GlobalConfig.Server.Users[i].SetEnvVars()
+	}
+
 	// Set repository configuration defaults when not set after
 	// loading the YAML file.
 	c.SetRepositoryConfigDefaults()
@@ -150,6 +230,13 @@ func (c *Config) SetRepositoryConfigDefaults() {
 				c.RepoConfigs[i].Provider = p
 			}
 		}
+
+		// reference repo pipeline id to pipeline data if present and match found.
+		for _, p := range c.GlobalConfig.Pipelines {
+			if r.PipelineId == p.Id {
+				c.RepoConfigs[i].Pipeline = p
+			}
+		}
 	}
 }
 
@@ -161,13 +248,25 @@ func (p *Provider) SetEnvVars() {
 		if IsEnvVar(v.Value) {
 			value, ok := EnvVarValue(v.Value)
 			if !ok {
-				fmt.Printf("config: warning: no env var set for %v\n", v.Name)
+				fmt.Fprintf(os.Stderr, "config: warning: no env var set for %v\n", v.Name)
 			}
 			p.Variables[i].Value = value
 		}
 	}
 }
 
+// SetEnvVars substitutes the password with the value of the referenced
+// environment variable when present.
+func (u *User) SetEnvVars() {
+	if IsEnvVar(u.Password) {
+		value, ok := EnvVarValue(u.Password)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "config: warning: no env var set for password of user %v\n", u.Name)
+		}
+		u.Password = value
+	}
+}
+
 // EnvVarValue returns the value of the environment variable.
 // If environment value is not set, the bool will be false.
 func EnvVarValue(v string) (string, bool) {
@@ -7,6 +7,7 @@ require (
 	github.com/alecthomas/units v0.0.0-20190924025748-f65c72e2690d // indirect
 	github.com/fsnotify/fsnotify v1.4.7
 	github.com/golang/protobuf v1.3.2 // indirect
+	github.com/klauspost/compress v1.10.3
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/onsi/ginkgo v1.4.0
 	github.com/onsi/gomega v1.3.0
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	annotationsFile = "/annotations.yaml"
+)
+
+// BlockedLabel is the label which blocks the promotion of a revision.
+const BlockedLabel = "blocked"
+
+// ValidLabelRegex is the pattern a label key has to match.
+const ValidLabelRegex = "^[a-zA-Z0-9_.-]+$"
+
+// Annotations contains the notes and labels attached to a revision.
+type Annotations struct {
+	Notes  []Note            `yaml:"notes,omitempty"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+// A Note is a free-form text attached to a revision.
+type Note struct {
+	Time time.Time `yaml:"time" json:"time"`
+	User string    `yaml:"user" json:"user"`
+	Text string    `yaml:"text" json:"text"`
+}
+
+// NewAnnotationsFromFile returns the Annotations loaded from a file.
+func NewAnnotationsFromFile(name string) (*Annotations, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Annotations{}
+	if err := yaml.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return a, nil
+}
+
+// Save writes the annotations to a file.
+func (a *Annotations) Save(name string) error {
+	data, err := yaml.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, data, 0644)
+}
+
+// LastNote returns the text of the most recent note, an empty string when
+// there are no notes.
+func (a *Annotations) LastNote() string {
+	if a == nil || len(a.Notes) == 0 {
+		return ""
+	}
+	return a.Notes[len(a.Notes)-1].Text
+}
+
+// LabelStrings returns the labels as sorted key=value strings.
+func (a *Annotations) LabelStrings() []string {
+	var labels []string
+	if a == nil {
+		return labels
+	}
+
+	for k, v := range a.Labels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// HasLabel returns true when the label is set. When value is not empty,
+// the label should have that value too.
+func (a *Annotations) HasLabel(key string, value string) bool {
+	if a == nil {
+		return false
+	}
+
+	v, ok := a.Labels[key]
+	return ok && (value == "" || v == value)
+}
+
+// MatchesLabels returns true when the revision carries all the labels of
+// the selector. A selector is a key=value string or only a key, which
+// matches any value.
+func (re *Revision) MatchesLabels(selectors ...string) bool {
+	for _, s := range selectors {
+		key, value := ParseLabel(s)
+		if !re.Annotations.HasLabel(key, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLabel splits a key=value label in its key and value.
+func ParseLabel(label string) (string, string) {
+	if i := strings.Index(label, "="); i >= 0 {
+		return label[:i], label[i+1:]
+	}
+	return label, ""
+}
+
+// IsLabel returns true when the value is formatted as a key=value label.
+func IsLabel(value string) bool {
+	key, _ := ParseLabel(value)
+	matched, _ := regexp.MatchString(ValidLabelRegex, key)
+	return matched && strings.Contains(value, "=")
+}
+
+// The Annotate method adds a note and sets labels on a revision, which is
+// referenced by tag or revision id. A label with an empty value, like
+// "ticket=", removes the label.
+func (r *Repository) Annotate(tagOrRev string, note string, labels []string) error {
+	rev := r.revisionByTagOrRevId(tagOrRev)
+	if rev == nil {
+		return fmt.Errorf("tag or revision %s not found", tagOrRev)
+	}
+
+	if rev.Annotations == nil {
+		rev.Annotations = &Annotations{}
+	}
+	a := rev.Annotations
+
+	if note != "" {
+		a.Notes = append(a.Notes, Note{
+			Time: time.Now(),
+			User: currentUser(),
+			Text: note,
+		})
+	}
+
+	for _, l := range labels {
+		if !IsLabel(l) {
+			return fmt.Errorf("label %s not valid, should be key=value", l)
+		}
+
+		key, value := ParseLabel(l)
+		if value == "" {
+			delete(a.Labels, key)
+			continue
+		}
+
+		if a.Labels == nil {
+			a.Labels = make(map[string]string)
+		}
+		a.Labels[key] = value
+	}
+
+	return a.Save(r.getAnnotationsPath(rev))
+}
+
+// getAnnotationsPath returns the path of the annotations file of a
+// revision.
+func (r *Repository) getAnnotationsPath(rev *Revision) string {
+	return r.getRevisionDir(rev) + annotationsFile
+}
+
+// loadAnnotations loads the annotations of a revision when present.
+func (r *Repository) loadAnnotations(rev *Revision) error {
+	a, err := NewAnnotationsFromFile(r.getAnnotationsPath(rev))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	rev.Annotations = a
+	return nil
+}
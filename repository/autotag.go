@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/catay/rrst/config"
+	"strings"
+	"time"
+)
+
+// The ApplyTagRules method evaluates the automatic tagging rules of the
+// repository and sets or moves the tags accordingly. It returns the names
+// of the tags which changed.
+func (r *Repository) ApplyTagRules() ([]string, error) {
+	var changed []string
+
+	for _, rule := range r.TagRules {
+		follow := rule.Follow
+		if follow == "" {
+			follow = config.DefaultLatestRevisionTag
+		}
+
+		rev := r.revisionOfTagAt(follow, time.Now().Add(-rule.Delay))
+		if rev == nil {
+			continue
+		}
+
+		tagname := expandTagTemplate(rule.Tag, rev.Created)
+		if rule.Once && r.isTag(tagname) {
+			continue
+		}
+
+		ok, err := r.tag(tagname, rev.Id, "tag rule "+rule.Tag)
+		if err != nil {
+			return changed, fmt.Errorf("tag rule %s: %s", rule.Tag, err)
+		}
+
+		if ok {
+			changed = append(changed, tagname)
+		}
+	}
+
+	return changed, nil
+}
+
+// updateTags moves the latest tag to the latest revision and applies the
+// tag rules. It returns true when the latest tag moved. Every operation
+// creating revisions, update, refresh and import, ends with it, so the
+// tag rules apply no matter how a revision got created. Uploads and
+// copies create their revisions through a refresh.
+func (r *Repository) updateTags() (bool, error) {
+	changed, err := r.tagLatestRevision(config.DefaultLatestRevisionTag)
+	if err != nil {
+		return changed, err
+	}
+
+	_, err = r.ApplyTagRules()
+	return changed, err
+}
+
+// revisionOfTagAt returns the revision a tag pointed to at the given time.
+// For the latest tag this is the most recent revision created before that
+// time, for other tags it's looked up in the tag journal.
+// The returned Revision will be nil when not found.
+func (r *Repository) revisionOfTagAt(tagname string, t time.Time) *Revision {
+	var rev *Revision
+
+	if tagname == config.DefaultLatestRevisionTag {
+		for _, v := range r.Revisions {
+			if !v.Created.After(t) && (rev == nil || v.Id > rev.Id) {
+				rev = v
+			}
+		}
+		return rev
+	}
+
+	changes, err := r.TagHistory(tagname)
+	if err != nil {
+		return nil
+	}
+
+	for _, c := range changes {
+		if c.Time.After(t) {
+			break
+		}
+		rev = r.revisionById(c.Revision)
+	}
+
+	return rev
+}
+
+// expandTagTemplate replaces the YYYY, MM and DD placeholders of a tag
+// name with the date.
+func expandTagTemplate(tagname string, t time.Time) string {
+	t = t.Local()
+	return strings.NewReplacer(
+		"YYYY", fmt.Sprintf("%04d", t.Year()),
+		"MM", fmt.Sprintf("%02d", t.Month()),
+		"DD", fmt.Sprintf("%02d", t.Day()),
+	).Replace(tagname)
+}
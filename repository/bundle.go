@@ -0,0 +1,652 @@
+package repository
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/catay/rrst/config"
+	"github.com/catay/rrst/version"
+	"github.com/klauspost/compress/zstd"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	bundleFormatVersion = 1
+	bundleManifestFile  = "bundle.yaml"
+	bundleSignatureFile = "bundle.yaml.sig"
+	bundleMetadataDir   = "metadata/"
+	bundlePackagesDir   = "packages/"
+	maxBundleManifest   = 64 << 20
+)
+
+var (
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	gzipMagic = []byte{0x1f, 0x8b}
+)
+
+// Errors returned by the bundle functions.
+var (
+	ErrBundleNotTrusted = errors.New("bundle signature not trusted")
+	ErrBundleCorrupt    = errors.New("bundle corrupt")
+)
+
+// A BundleManifest describes the content of an export bundle. It lists
+// the metadata files of the exported revision and all the packages it
+// references with their SHA-256 checksums. Packages of an incremental
+// bundle already referenced by the Since revision are excluded from the
+// bundle, they should be present on the importing side.
+type BundleManifest struct {
+	Version     int          `yaml:"version"`
+	Repository  string       `yaml:"repository"`
+	Revision    int64        `yaml:"revision"`
+	Tag         string       `yaml:"tag,omitempty"`
+	Since       int64        `yaml:"since,omitempty"`
+	Created     time.Time    `yaml:"created"`
+	RrstVersion string       `yaml:"rrst_version"`
+	Metadata    []BundleFile `yaml:"metadata"`
+	Packages    []BundleFile `yaml:"packages"`
+}
+
+// A BundleFile is a file listed in a bundle manifest.
+type BundleFile struct {
+	Path     string `yaml:"path"`
+	Size     int64  `yaml:"size"`
+	Sha256   string `yaml:"sha256"`
+	Excluded bool   `yaml:"excluded,omitempty"`
+}
+
+// A Bundle is an export bundle opened for import.
+type Bundle struct {
+	Manifest  *BundleManifest
+	data      []byte
+	signature []byte
+	f         *os.File
+	dec       *zstd.Decoder
+	tr        *tar.Reader
+}
+
+// ImportResult contains the outcome of a bundle import.
+type ImportResult struct {
+	Revision *Revision
+	Created  bool
+	Imported int
+	Present  int
+}
+
+// LoadSigningKey loads a PEM encoded PKCS #8 ed25519 private key.
+func LoadSigningKey(name string) (ed25519.PrivateKey, error) {
+	block, err := readPEMFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	k, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 private key", name)
+	}
+	return k, nil
+}
+
+// LoadTrustedKey loads a PEM encoded PKIX ed25519 public key.
+func LoadTrustedKey(name string) (ed25519.PublicKey, error) {
+	block, err := readPEMFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	k, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 public key", name)
+	}
+	return k, nil
+}
+
+// The Export method writes a revision, referenced by tag or revision id,
+// to a bundle file. The bundle contains the revision metadata, the
+// referenced packages and the manifest signed with the key. When since
+// references another revision, the packages of that revision are left
+// out of the bundle. The bundle is compressed with zstd or gzip when the
+// file name ends with .zst or .gz.
+func (r *Repository) Export(tagOrRev string, since string, filename string, key ed25519.PrivateKey) (*BundleManifest, error) {
+	rev := r.revisionByTagOrRevId(tagOrRev)
+	if rev == nil {
+		return nil, fmt.Errorf("tag or revision %s not found", tagOrRev)
+	}
+
+	m := &BundleManifest{
+		Version:     bundleFormatVersion,
+		Repository:  r.Name,
+		Revision:    rev.Id,
+		Created:     time.Now(),
+		RrstVersion: version.FullVersionString,
+	}
+
+	if r.isTag(tagOrRev) {
+		m.Tag = tagOrRev
+	}
+
+	exclude := make(map[string]bool)
+	if since != "" {
+		base := r.revisionByTagOrRevId(since)
+		if base == nil {
+			return nil, fmt.Errorf("tag or revision %s not found", since)
+		}
+		m.Since = base.Id
+
+		packages, err := r.getMetadataPackageList(base)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range packages {
+			exclude[p.Location.Path] = true
+		}
+	}
+
+	metadata, err := r.getRepodataFiles(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Stat(r.getManifestPath(rev)); err == nil {
+		metadata = append(metadata, strings.TrimPrefix(manifestFile, "/"))
+	}
+
+	for _, v := range metadata {
+		f, err := newBundleFile(v, r.getRevisionDir(rev)+"/"+v)
+		if err != nil {
+			return nil, err
+		}
+		m.Metadata = append(m.Metadata, f)
+	}
+
+	packages, err := r.getMetadataPackageList(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range packages {
+		f, err := newBundleFile(p.Location.Path, r.ContentFilesPath+"/"+p.Location.Path)
+		if err != nil {
+			return nil, fmt.Errorf("revision %v is incomplete: %s", rev.Id, err)
+		}
+		f.Excluded = exclude[p.Location.Path]
+		m.Packages = append(m.Packages, f)
+	}
+
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)) + "\n"
+
+	return m, writeBundle(filename, func(tw *tar.Writer) error {
+		if err := writeTarData(tw, bundleManifestFile, data); err != nil {
+			return err
+		}
+
+		if err := writeTarData(tw, bundleSignatureFile, []byte(signature)); err != nil {
+			return err
+		}
+
+		for _, v := range m.Metadata {
+			if err := writeTarFile(tw, bundleMetadataDir+v.Path, r.getRevisionDir(rev)+"/"+v.Path, v.Size); err != nil {
+				return err
+			}
+		}
+
+		for _, v := range m.Packages {
+			if v.Excluded {
+				continue
+			}
+			if err := writeTarFile(tw, bundlePackagesDir+v.Path, r.ContentFilesPath+"/"+v.Path, v.Size); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// OpenBundle opens a bundle file and reads its manifest. The signature
+// of the manifest has to be checked with Verify before importing.
+func OpenBundle(filename string) (*Bundle, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{f: f}
+
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(len(zstdMagic))
+
+	var rd io.Reader = br
+	switch {
+	case bytes.HasPrefix(magic, zstdMagic):
+		b.dec, err = zstd.NewReader(br)
+		rd = b.dec
+	case bytes.HasPrefix(magic, gzipMagic):
+		rd, err = gzip.NewReader(br)
+	}
+
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	b.tr = tar.NewReader(rd)
+
+	if b.data, err = b.readEntry(bundleManifestFile); err == nil {
+		b.signature, err = b.readEntry(bundleSignatureFile)
+	}
+
+	if err != nil {
+		b.Close()
+		return nil, err
+	}
+
+	b.Manifest = &BundleManifest{}
+	if err := yaml.Unmarshal(b.data, b.Manifest); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("%s: %s", ErrBundleCorrupt, err)
+	}
+
+	if b.Manifest.Version != bundleFormatVersion {
+		b.Close()
+		return nil, fmt.Errorf("bundle format version %v not supported", b.Manifest.Version)
+	}
+
+	return b, nil
+}
+
+// Verify checks the manifest is signed by one of the trusted keys.
+func (b *Bundle) Verify(trusted []ed25519.PublicKey) error {
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b.signature)))
+	if err != nil {
+		return ErrBundleNotTrusted
+	}
+
+	for _, k := range trusted {
+		if ed25519.Verify(k, b.data, signature) {
+			return nil
+		}
+	}
+	return ErrBundleNotTrusted
+}
+
+// Close closes the bundle file.
+func (b *Bundle) Close() error {
+	if b.dec != nil {
+		b.dec.Close()
+	}
+	return b.f.Close()
+}
+
+// The Import method imports the revision of a bundle. The packages
+// already present are not imported again, but should have the same
+// content. The packages excluded from an incremental bundle should be
+// present already. An existing revision with the same metadata is reused
+// instead of creating a new one. When tag is true, the tag the revision
+// was exported with is created as well.
+func (r *Repository) Import(b *Bundle, tag bool) (*ImportResult, error) {
+	m := b.Manifest
+	res := &ImportResult{}
+
+	// Index the files expected in the bundle by their name in the bundle.
+	expected := make(map[string]BundleFile)
+	for _, v := range m.Metadata {
+		if !isSafeRelPath(v.Path) || !(v.Path == strings.TrimPrefix(manifestFile, "/") || strings.HasPrefix(v.Path, "repodata/")) {
+			return nil, fmt.Errorf("%s: metadata file %s not allowed", ErrBundleCorrupt, v.Path)
+		}
+		expected[bundleMetadataDir+v.Path] = v
+	}
+
+	for _, v := range m.Packages {
+		if !isSafeRelPath(v.Path) {
+			return nil, fmt.Errorf("%s: package path %s not allowed", ErrBundleCorrupt, v.Path)
+		}
+		if !v.Excluded {
+			expected[bundlePackagesDir+v.Path] = v
+		}
+	}
+
+	staging, err := ioutil.TempDir(r.ContentTmpPath, "import")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	// Extract the bundle in the staging directory, verifying the
+	// checksum of each file.
+	for {
+		hdr, err := b.tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", ErrBundleCorrupt, err)
+		}
+
+		f, ok := expected[hdr.Name]
+		if !ok || hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%s: unexpected file %s", ErrBundleCorrupt, hdr.Name)
+		}
+
+		// Reject a file of another size than in the signed manifest
+		// before writing anything, so a bad bundle can't fill the disk.
+		if hdr.Size != f.Size {
+			return nil, fmt.Errorf("%s: %s: size %v, expected %v", ErrBundleCorrupt, hdr.Name, hdr.Size, f.Size)
+		}
+
+		if err := extractBundleFile(b.tr, staging+"/"+hdr.Name, f); err != nil {
+			return nil, fmt.Errorf("%s: %s: %s", ErrBundleCorrupt, hdr.Name, err)
+		}
+		delete(expected, hdr.Name)
+	}
+
+	for k := range expected {
+		return nil, fmt.Errorf("%s: %s missing", ErrBundleCorrupt, k)
+	}
+
+	// Check the packages already present before moving anything into
+	// place, so a conflict doesn't leave a partial import behind.
+	present := make(map[string]bool)
+	for _, v := range m.Packages {
+		sum, err := sha256File(r.ContentFilesPath + "/" + v.Path)
+		switch {
+		case os.IsNotExist(err) && v.Excluded:
+			return nil, fmt.Errorf("package %s not present, import the bundle of revision %v first", v.Path, m.Since)
+		case os.IsNotExist(err):
+			continue
+		case err != nil:
+			return nil, err
+		case sum != v.Sha256:
+			return nil, fmt.Errorf("%s: %s", v.Path, ErrPackageConflict)
+		}
+		present[v.Path] = true
+	}
+
+	for _, v := range m.Packages {
+		if present[v.Path] {
+			res.Present++
+			continue
+		}
+
+		target := r.ContentFilesPath + "/" + v.Path
+		if err := r.checkLockedPackage(v.Path, staging+"/"+bundlePackagesDir+v.Path); err != nil {
+			return res, fmt.Errorf("%s: %s", v.Path, err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return res, err
+		}
+
+		if err := os.Rename(staging+"/"+bundlePackagesDir+v.Path, target); err != nil {
+			return res, err
+		}
+		res.Imported++
+	}
+
+	rev := r.revisionByRepomd(m)
+	if rev == nil {
+		rev, err = r.createRevisionWith(func(dir string) error {
+			for _, v := range m.Metadata {
+				if err := os.MkdirAll(filepath.Dir(dir+"/"+v.Path), 0755); err != nil {
+					return err
+				}
+				if err := os.Rename(staging+"/"+bundleMetadataDir+v.Path, dir+"/"+v.Path); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return res, err
+		}
+		res.Created = true
+	}
+	id := rev.Id
+
+	// The repository state gets reloaded when tagging the latest revision.
+	if _, err := r.tagLatestRevision(config.DefaultLatestRevisionTag); err != nil {
+		return res, err
+	}
+	res.Revision = r.revisionById(id)
+
+	if tag && m.Tag != "" && m.Tag != config.DefaultLatestRevisionTag {
+		if _, err := r.tag(m.Tag, id, fmt.Sprintf("imported from %s revision %v", m.Repository, m.Revision)); err != nil {
+			return res, err
+		}
+	}
+
+	_, err = r.updateTags()
+	return res, err
+}
+
+// revisionByRepomd returns the revision with the same repomd.xml as the
+// bundle. The returned Revision will be nil when not found.
+func (r *Repository) revisionByRepomd(m *BundleManifest) *Revision {
+	var sum string
+	for _, v := range m.Metadata {
+		if "/"+v.Path == repoXMLfile {
+			sum = v.Sha256
+		}
+	}
+
+	for _, rev := range r.Revisions {
+		if current, err := sha256File(r.getRevisionDir(rev) + repoXMLfile); err == nil && current == sum {
+			return rev
+		}
+	}
+	return nil
+}
+
+// readEntry reads the next entry of the bundle, which should have the
+// given name.
+func (b *Bundle) readEntry(name string) ([]byte, error) {
+	hdr, err := b.tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", ErrBundleCorrupt, err)
+	}
+
+	if hdr.Name != name {
+		return nil, fmt.Errorf("%s: expected %s, found %s", ErrBundleCorrupt, name, hdr.Name)
+	}
+
+	return ioutil.ReadAll(io.LimitReader(b.tr, maxBundleManifest))
+}
+
+// newBundleFile returns the BundleFile of a file.
+func newBundleFile(path string, name string) (BundleFile, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return BundleFile{}, err
+	}
+
+	sum, err := sha256File(name)
+	if err != nil {
+		return BundleFile{}, err
+	}
+
+	return BundleFile{Path: path, Size: fi.Size(), Sha256: sum}, nil
+}
+
+// writeBundle creates a bundle file, compressed depending on the file
+// name extension. The file is written under a temporary name first.
+func writeBundle(filename string, fill func(tw *tar.Writer) error) error {
+	tmpfile := filename + tmpSuffix
+	f, err := os.Create(tmpfile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpfile)
+	defer f.Close()
+
+	var w io.WriteCloser
+	switch {
+	case strings.HasSuffix(filename, ".zst"):
+		w, err = zstd.NewWriter(f)
+	case strings.HasSuffix(filename, ".gz") || strings.HasSuffix(filename, ".tgz"):
+		w = gzip.NewWriter(f)
+	default:
+		w = nopWriteCloser{f}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	if err := fill(tw); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpfile, filename)
+}
+
+// writeTarData writes a tar entry with the data as content.
+func writeTarData(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := tw.Write(data)
+	return err
+}
+
+// writeTarFile writes a tar entry with the content of a file.
+func writeTarFile(tw *tar.Writer, name string, filename string, size int64) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if fi.Size() != size {
+		return fmt.Errorf("%s changed during export", filename)
+	}
+
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: fi.ModTime(),
+	}
+
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// extractBundleFile writes the content of a bundle entry to a file and
+// verifies its checksum.
+func extractBundleFile(rd io.Reader, name string, expected BundleFile) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Never write more than the size in the manifest.
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(rd, expected.Size))
+	if err != nil {
+		return err
+	}
+
+	if n != expected.Size {
+		return fmt.Errorf("size %v, expected %v", n, expected.Size)
+	}
+
+	if fmt.Sprintf("%x", h.Sum(nil)) != expected.Sha256 {
+		return fmt.Errorf("checksum mismatch")
+	}
+
+	return f.Close()
+}
+
+// isSafeRelPath checks a path taken from a bundle manifest or metadata is
+// a clean relative path, which can't point outside the directory it gets
+// written in.
+func isSafeRelPath(path string) bool {
+	return path != "" && !filepath.IsAbs(path) && filepath.Clean(path) == path &&
+		path != ".." && !strings.HasPrefix(path, "../")
+}
+
+// readPEMFile reads the first PEM block of a file.
+func readPEMFile(name string) (*pem.Block, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", name)
+	}
+	return block, nil
+}
+
+// nopWriteCloser adds a no-op Close method to a Writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
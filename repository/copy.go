@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/catay/rrst/repository/repomd"
+	"github.com/catay/rrst/util/file"
+	"path"
+)
+
+// The Copy method copies the packages matching the package specs of a
+// tag or revision into the files directory of a local destination
+// repository and creates a new metadata revision for it.
+// When hardlink is true the package files are hard linked instead of
+// copied when possible. When withDeps is true the missing dependencies
+// of the selected packages are resolved and copied as well.
+// It returns the number of package files copied.
+func (r *Repository) Copy(tagOrRev string, specs []string, dst *Repository, hardlink bool, withDeps bool) (int, error) {
+	if dst.RemoteURI != "" {
+		return 0, fmt.Errorf("destination repository %s is not a local repository", dst.Name)
+	}
+
+	if dst.Name == r.Name {
+		return 0, fmt.Errorf("source and destination repository are the same")
+	}
+
+	rev := r.revisionByTagOrRevId(tagOrRev)
+	if rev == nil {
+		return 0, fmt.Errorf("tag or revision %s not found", tagOrRev)
+	}
+
+	packages, err := r.getMetadataPackageList(rev)
+	if err != nil {
+		return 0, err
+	}
+
+	selected := selectPackages(packages, specs)
+	if len(selected) == 0 {
+		return 0, fmt.Errorf("no packages matching %v found", specs)
+	}
+
+	if withDeps {
+		var installed []repomd.RpmPackage
+		if dstRev, ok := dst.getLatestRevision(); ok {
+			installed, err = dst.getMetadataPackageList(dstRev)
+			if err != nil {
+				return 0, err
+			}
+		}
+		selected = resolveMissingDeps(selected, packages, installed)
+	}
+
+	// The locations come from the metadata, those pointing outside the
+	// files dir are refused before anything gets copied.
+	for _, p := range selected {
+		if !isSafeRelPath(p.Location.Path) {
+			return 0, fmt.Errorf("package location %s not allowed", p.Location.Path)
+		}
+	}
+
+	var copied int
+	for _, p := range selected {
+		src := r.ContentFilesPath + "/" + p.Location.Path
+		target := dst.ContentFilesPath + "/" + p.Location.Path
+
+		if file.IsRegularFile(target) {
+			fmt.Printf("Skipping %v, already present\n", p.Location.Path)
+			continue
+		}
+
+		if err := dst.checkLockedPackage(p.Location.Path, src); err != nil {
+			return copied, fmt.Errorf("copying %s failed: %s", p.Location.Path, err)
+		}
+
+		if hardlink {
+			err = file.LinkOrCopyFile(src, target)
+		} else {
+			err = file.CopyFile(src, target)
+		}
+
+		if err != nil {
+			return copied, fmt.Errorf("copying %s failed: %s", p.Location.Path, err)
+		}
+
+		fmt.Printf("Copying %v\n", p.Location.Path)
+		copied++
+	}
+
+	if copied == 0 {
+		return copied, nil
+	}
+
+	return copied, dst.Refresh()
+}
+
+// selectPackages returns the packages matching at least one of the
+// package specs. A spec is a shell pattern matched against the name,
+// name.arch, name-version-release and name-version-release.arch of a
+// package.
+func selectPackages(packages []repomd.RpmPackage, specs []string) []repomd.RpmPackage {
+	var selected []repomd.RpmPackage
+
+	for _, p := range packages {
+		nvr := p.Name + "-" + p.Version.Ver + "-" + p.Version.Rel
+		names := []string{p.Name, p.Name + "." + p.Arch, nvr, nvr + "." + p.Arch}
+
+	match:
+		for _, spec := range specs {
+			for _, n := range names {
+				if ok, _ := path.Match(spec, n); ok {
+					selected = append(selected, p)
+					break match
+				}
+			}
+		}
+	}
+
+	return selected
+}
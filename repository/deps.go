@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"github.com/catay/rrst/repository/repomd"
+	"sort"
+	"strings"
+)
+
+// The CheckDeps method verifies the dependency closure of a tag or
+// revision, like repoclosure does. The requirements can also be satisfied
+// by the packages of the extra package lists, for example the packages of
+// a base repository. It returns the unresolved requirements.
+//
+// File requirements can only be checked against the files listed in the
+// primary metadata, which createrepo limits to the files in /etc, the bin
+// directories and /usr/lib/sendmail. Other file requirements which aren't
+// provided are returned as DepNotChecked. Rich dependencies, like
+// "(foo if bar)", aren't evaluated and are returned as DepUnsupported.
+// Those shouldn't be taken as unresolved. Conflicts and obsoletes aren't
+// checked.
+func (r *Repository) CheckDeps(tagOrRev string, with ...[]repomd.RpmPackage) ([]UnresolvedDep, error) {
+	packages, err := r.Packages(tagOrRev)
+	if err != nil {
+		return nil, err
+	}
+
+	return unresolvedDeps(packages, with...), nil
+}
+
+// Statuses of the requirements returned by CheckDeps.
+const (
+	DepUnresolved  = "unresolved"
+	DepNotChecked  = "not checked"
+	DepUnsupported = "unsupported"
+)
+
+// UnresolvedDep describes a package requirement which is not provided
+// by any package. The Status tells if it's really unresolved, or if it
+// couldn't be checked.
+type UnresolvedDep struct {
+	Package  string
+	Requires string
+	Status   string
+}
+
+// primaryFile returns true when the file is one of the files createrepo
+// lists in the primary metadata.
+func primaryFile(name string) bool {
+	return strings.HasPrefix(name, "/etc/") || strings.Contains(name, "bin/") || name == "/usr/lib/sendmail"
+}
+
+// provider links a provided capability to the providing package.
+type provider struct {
+	pkg   *repomd.RpmPackage
+	entry repomd.RpmEntry
+}
+
+// providerIndex maps a capability name to the packages providing it.
+// Package names, provides entries and file paths are all capabilities.
+type providerIndex map[string][]provider
+
+// newProviderIndex returns a providerIndex for the given package lists.
+func newProviderIndex(lists ...[]repomd.RpmPackage) providerIndex {
+	pi := make(providerIndex)
+	for _, packages := range lists {
+		for i := range packages {
+			pi.add(&packages[i])
+		}
+	}
+	return pi
+}
+
+// add registers all the capabilities of a package in the index.
+func (pi providerIndex) add(p *repomd.RpmPackage) {
+	self := repomd.RpmEntry{Name: p.Name, Flags: "EQ", EVR: p.Version}
+	pi[p.Name] = append(pi[p.Name], provider{p, self})
+
+	for _, e := range p.Format.Provides {
+		pi[e.Name] = append(pi[e.Name], provider{p, e})
+	}
+
+	for _, f := range p.Format.Files {
+		pi[f] = append(pi[f], provider{p, repomd.RpmEntry{Name: f}})
+	}
+}
+
+// whatProvides returns the packages providing the required capability.
+// A provides entry without a version satisfies any versioned requirement,
+// like rpm does.
+func (pi providerIndex) whatProvides(req repomd.RpmEntry) []*repomd.RpmPackage {
+	var packages []*repomd.RpmPackage
+	for _, p := range pi[req.Name] {
+		if p.entry.Flags != "EQ" || req.Matches(p.entry.EVR) {
+			packages = append(packages, p.pkg)
+		}
+	}
+	return packages
+}
+
+// isRichDependency returns true for the boolean dependencies of rpm 4.13,
+// like "(foo if bar)", which are always enclosed in parentheses.
+func isRichDependency(req repomd.RpmEntry) bool {
+	return strings.HasPrefix(req.Name, "(")
+}
+
+// isRpmlibRequirement returns true for the rpmlib() requirements which
+// are provided by rpm itself and not by a package.
+func isRpmlibRequirement(req repomd.RpmEntry) bool {
+	return strings.HasPrefix(req.Name, "rpmlib(")
+}
+
+// unresolvedDeps returns the requirements of the packages which can't be
+// satisfied by the packages themselves or by the extra package lists.
+func unresolvedDeps(packages []repomd.RpmPackage, with ...[]repomd.RpmPackage) []UnresolvedDep {
+	var unresolved []UnresolvedDep
+
+	pi := newProviderIndex(append(with, packages)...)
+
+	for _, p := range packages {
+		for _, req := range p.Format.Requires {
+			if isRpmlibRequirement(req) || len(pi.whatProvides(req)) > 0 {
+				continue
+			}
+			status := DepUnresolved
+			switch {
+			case isRichDependency(req):
+				status = DepUnsupported
+			case strings.HasPrefix(req.Name, "/") && !primaryFile(req.Name):
+				status = DepNotChecked
+			}
+
+			unresolved = append(unresolved, UnresolvedDep{
+				Package:  p.Name + "-" + p.Version.String() + "." + p.Arch,
+				Requires: req.String(),
+				Status:   status,
+			})
+		}
+	}
+
+	sort.Slice(unresolved, func(i, j int) bool {
+		if unresolved[i].Package != unresolved[j].Package {
+			return unresolved[i].Package < unresolved[j].Package
+		}
+		return unresolved[i].Requires < unresolved[j].Requires
+	})
+
+	return unresolved
+}
+
+// resolveMissingDeps returns the selected packages extended with the
+// candidate packages required to satisfy the requirements which are not
+// provided by the selected or installed packages. Unresolvable
+// requirements are silently ignored.
+func resolveMissingDeps(selected, candidates, installed []repomd.RpmPackage) []repomd.RpmPackage {
+	available := newProviderIndex(candidates)
+	present := newProviderIndex(installed)
+
+	picked := make(map[string]bool)
+	for i := range selected {
+		picked[selected[i].Location.Path] = true
+		present.add(&selected[i])
+	}
+
+	// Walk the selected list while it grows, so the requirements of the
+	// added dependencies get resolved as well.
+	for i := 0; i < len(selected); i++ {
+		for _, req := range selected[i].Format.Requires {
+			if isRpmlibRequirement(req) || len(present.whatProvides(req)) > 0 {
+				continue
+			}
+
+			p := bestProvider(available.whatProvides(req), selected[i].Arch)
+			if p == nil || picked[p.Location.Path] {
+				continue
+			}
+
+			picked[p.Location.Path] = true
+			present.add(p)
+			selected = append(selected, *p)
+		}
+	}
+
+	return selected
+}
+
+// bestProvider picks a provider matching the architecture, falling back to
+// noarch packages and finally to the first provider available.
+func bestProvider(providers []*repomd.RpmPackage, arch string) *repomd.RpmPackage {
+	if len(providers) == 0 {
+		return nil
+	}
+
+	for _, want := range []string{arch, "noarch"} {
+		for _, p := range providers {
+			if p.Arch == want {
+				return p
+			}
+		}
+	}
+
+	return providers[0]
+}
@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/catay/rrst/repository/repomd"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Changes of a package between tags or revisions.
+const (
+	PackageAdded      = "added"
+	PackageRemoved    = "removed"
+	PackageUpgraded   = "upgraded"
+	PackageDowngraded = "downgraded"
+	PackageChanged    = "changed"
+)
+
+// A PackageDiff holds the versions of a package.arch per tag or revision
+// and the classification of the change from the first to the last tag or
+// revision.
+type PackageDiff struct {
+	Package  string
+	Versions [][]repomd.EVR
+	Change   string
+}
+
+// A PackageChangelog holds the changelog entries added to a package
+// upgraded between two tags or revisions, newest first.
+type PackageChangelog struct {
+	Package string
+	From    repomd.EVR
+	To      repomd.EVR
+	Entries []repomd.ChangelogEntry
+}
+
+// The PackageVersions method returns a hash with the package.arch name
+// as key and per tag or revision the versions of the package, sorted from
+// old to new. Update repositories often carry several versions of the
+// same package.arch, so a tag or revision can have more than one.
+func (r *Repository) PackageVersions(tagsOrRevs ...string) (map[string][][]repomd.EVR, error) {
+	packageLists, err := r.packageLists(tagsOrRevs...)
+	if err != nil {
+		return nil, err
+	}
+	return PackageVersionsOf(packageLists...), nil
+}
+
+// The Changelogs method returns the changelog entries of the packages of
+// a tag or revision, with package.arch-version as key. When package.arch
+// names are given only the entries of those packages are returned, which
+// keeps the memory use low on large repositories.
+func (r *Repository) Changelogs(tagOrRev string, packages ...string) (map[string][]repomd.ChangelogEntry, error) {
+	rev := r.revisionByTagOrRevId(tagOrRev)
+	if rev == nil {
+		return nil, fmt.Errorf("tag or revision %s not found", tagOrRev)
+	}
+
+	return r.getMetadataChangelogs(rev, packages...)
+}
+
+// packageLists returns the packages of each tag or revision.
+func (r *Repository) packageLists(tagsOrRevs ...string) ([][]repomd.RpmPackage, error) {
+	var packageLists [][]repomd.RpmPackage
+	for _, t := range tagsOrRevs {
+		packages, err := r.PackageIndex(t)
+		if err != nil {
+			return nil, err
+		}
+		packageLists = append(packageLists, packages)
+	}
+	return packageLists, nil
+}
+
+// getMetadataChangelogs returns the changelog entries of the packages of
+// a revision, with package.arch-version as key, limited to the given
+// package.arch names when present. The packages are read from the
+// metadata one at a time.
+func (r *Repository) getMetadataChangelogs(rev *Revision, packages ...string) (map[string][]repomd.ChangelogEntry, error) {
+	f, err := r.openMetadata(rev, "other")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ot, err := repomd.NewOtherReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool)
+	for _, p := range packages {
+		wanted[p] = true
+	}
+
+	changelogs := make(map[string][]repomd.ChangelogEntry)
+	for {
+		p, err := ot.Next()
+		if err == io.EOF {
+			return changelogs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := p.Name + "." + p.Arch
+		if len(wanted) > 0 && !wanted[name] {
+			continue
+		}
+		changelogs[name+"-"+p.Version.String()] = p.Changelog
+	}
+}
+
+// PackageVersionsOf returns a hash with the package.arch name as key and
+// per package list the versions of the package, sorted from old to new.
+// The package lists can come from different repositories.
+func PackageVersionsOf(packageLists ...[]repomd.RpmPackage) map[string][][]repomd.EVR {
+	packageMap := make(map[string][][]repomd.EVR)
+
+	for i, packages := range packageLists {
+		for _, p := range packages {
+			packageName := p.Name + "." + p.Arch
+			if _, ok := packageMap[packageName]; !ok {
+				packageMap[packageName] = make([][]repomd.EVR, len(packageLists))
+			}
+
+			packageMap[packageName][i] = append(packageMap[packageName][i], p.Version)
+		}
+	}
+
+	for _, v := range packageMap {
+		for i := range v {
+			sortEVRs(v[i])
+		}
+	}
+
+	return packageMap
+}
+
+// DiffPackages returns the packages with different versions between
+// package lists, sorted by package.arch name. The change is classified
+// from the first to the last package list.
+func DiffPackages(packageLists ...[]repomd.RpmPackage) []PackageDiff {
+	var diffs []PackageDiff
+	for k, v := range PackageVersionsOf(packageLists...) {
+		// only keep packages with a different version in a tagged revision
+		var differs bool
+		for _, a := range v[1:] {
+			if !equalEVRs(v[0], a) {
+				differs = true
+				break
+			}
+		}
+
+		if !differs {
+			continue
+		}
+
+		// The first and last versions can be the same when only the
+		// ones in between differ.
+		change := ClassifyChange(v[0], v[len(v)-1])
+		if change == "" {
+			change = PackageChanged
+		}
+
+		diffs = append(diffs, PackageDiff{
+			Package:  k,
+			Versions: v,
+			Change:   change,
+		})
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Package < diffs[j].Package
+	})
+
+	return diffs
+}
+
+// UpgradeChangelogs returns the changelog entries added to the packages
+// upgraded in the diffs between two package lists. The changelogs of both
+// are keyed by package.arch-version. When several versions of a package
+// are present the newest ones are compared.
+func UpgradeChangelogs(diffs []PackageDiff, from map[string][]repomd.ChangelogEntry, to map[string][]repomd.ChangelogEntry) []PackageChangelog {
+	var changelogs []PackageChangelog
+	for _, d := range diffs {
+		if d.Change != PackageUpgraded {
+			continue
+		}
+
+		first, last := d.Versions[0], d.Versions[len(d.Versions)-1]
+		c := PackageChangelog{
+			Package: d.Package,
+			From:    first[len(first)-1],
+			To:      last[len(last)-1],
+		}
+		c.Entries = repomd.ChangelogSince(from[d.Package+"-"+c.From.String()], to[d.Package+"-"+c.To.String()])
+		changelogs = append(changelogs, c)
+	}
+
+	return changelogs
+}
+
+// ClassifyChange classifies the change between the versions of a package
+// in two tags or revisions. When several versions are present the newest
+// ones are compared. PackageChanged is returned when the newest versions
+// are the same but others were added or removed.
+func ClassifyChange(from []repomd.EVR, to []repomd.EVR) string {
+	switch {
+	case len(from) == 0 && len(to) > 0:
+		return PackageAdded
+	case len(from) > 0 && len(to) == 0:
+		return PackageRemoved
+	case len(from) == 0 && len(to) == 0:
+		return ""
+	}
+
+	switch repomd.CompareEVR(from[len(from)-1], to[len(to)-1]) {
+	case -1:
+		return PackageUpgraded
+	case 1:
+		return PackageDowngraded
+	}
+
+	if !equalEVRs(from, to) {
+		return PackageChanged
+	}
+	return ""
+}
+
+// VersionsString returns the versions as a comma separated string, or -
+// when there are none.
+func VersionsString(evrs []repomd.EVR) string {
+	if len(evrs) == 0 {
+		return "-"
+	}
+
+	var versions []string
+	for _, e := range evrs {
+		versions = append(versions, e.String())
+	}
+	return strings.Join(versions, ",")
+}
+
+// sortEVRs sorts the versions from old to new the way rpm does.
+func sortEVRs(evrs []repomd.EVR) {
+	sort.SliceStable(evrs, func(i, j int) bool {
+		return repomd.CompareEVR(evrs[i], evrs[j]) < 0
+	})
+}
+
+// equalEVRs returns true when both sorted lists hold the same versions.
+func equalEVRs(a []repomd.EVR, b []repomd.EVR) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if repomd.CompareEVR(a[i], b[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package repository_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/catay/rrst/repository"
+	"github.com/catay/rrst/repository/repomd"
+)
+
+var _ = Describe("Diff", func() {
+
+	pkg := func(name string, arch string, epoch string, ver string, rel string) repomd.RpmPackage {
+		return repomd.RpmPackage{
+			Name:    name,
+			Arch:    arch,
+			Version: repomd.EVR{Epoch: epoch, Ver: ver, Rel: rel},
+		}
+	}
+
+	evr := func(epoch string, ver string, rel string) repomd.EVR {
+		return repomd.EVR{Epoch: epoch, Ver: ver, Rel: rel}
+	}
+
+	Describe("Given a function PackageVersionsOf(packageLists ...[]repomd.RpmPackage)", func() {
+		It("should return the versions per package list sorted from old to new", func() {
+			versions := PackageVersionsOf(
+				[]repomd.RpmPackage{
+					pkg("foo", "x86_64", "0", "1.10", "1"),
+					pkg("foo", "x86_64", "0", "1.9", "1"),
+					pkg("foo", "noarch", "0", "1.0", "1"),
+				},
+				[]repomd.RpmPackage{
+					pkg("foo", "x86_64", "1", "1.0", "1"),
+					pkg("bar", "x86_64", "0", "2.0", "1"),
+				},
+			)
+
+			Expect(versions).To(HaveLen(3))
+			Expect(versions["foo.x86_64"]).To(Equal([][]repomd.EVR{
+				{evr("0", "1.9", "1"), evr("0", "1.10", "1")},
+				{evr("1", "1.0", "1")},
+			}))
+			Expect(versions["foo.noarch"][1]).To(BeEmpty())
+			Expect(versions["bar.x86_64"][0]).To(BeEmpty())
+		})
+	})
+
+	Describe("Given a function ClassifyChange(from, to []repomd.EVR)", func() {
+		cases := []struct {
+			name     string
+			from, to []repomd.EVR
+			change   string
+		}{
+			{"added", nil, []repomd.EVR{evr("0", "1.0", "1")}, PackageAdded},
+			{"removed", []repomd.EVR{evr("0", "1.0", "1")}, nil, PackageRemoved},
+			{"upgraded", []repomd.EVR{evr("0", "1.0", "1")}, []repomd.EVR{evr("0", "1.0", "2")}, PackageUpgraded},
+			{"downgraded", []repomd.EVR{evr("0", "1.10", "1")}, []repomd.EVR{evr("0", "1.9", "1")}, PackageDowngraded},
+			{"upgraded by epoch only", []repomd.EVR{evr("0", "2.0", "1")}, []repomd.EVR{evr("1", "1.0", "1")}, PackageUpgraded},
+			{"downgraded by epoch only", []repomd.EVR{evr("2", "1.0", "1")}, []repomd.EVR{evr("1", "1.0", "1")}, PackageDowngraded},
+			{"upgraded comparing the newest versions", []repomd.EVR{evr("0", "1.0", "1"), evr("0", "1.1", "1")}, []repomd.EVR{evr("0", "1.2", "1")}, PackageUpgraded},
+			{"changed with the same newest version", []repomd.EVR{evr("0", "1.0", "1"), evr("0", "1.1", "1")}, []repomd.EVR{evr("0", "1.1", "1")}, PackageChanged},
+			{"unchanged", []repomd.EVR{evr("0", "1.0", "1"), evr("0", "1.1", "1")}, []repomd.EVR{evr("0", "1.0", "1"), evr("0", "1.1", "1")}, ""},
+			{"unchanged with a zero epoch left out", []repomd.EVR{evr("0", "1.0", "1")}, []repomd.EVR{evr("", "1.0", "1")}, ""},
+			{"absent in both", nil, nil, ""},
+		}
+
+		for _, c := range cases {
+			c := c
+			It("should classify a package "+c.name, func() {
+				Expect(ClassifyChange(c.from, c.to)).To(Equal(c.change))
+			})
+		}
+	})
+
+	Describe("Given a function DiffPackages(packageLists ...[]repomd.RpmPackage)", func() {
+		from := []repomd.RpmPackage{
+			pkg("same", "x86_64", "0", "1.0", "1"),
+			pkg("removed", "noarch", "0", "1.0", "1"),
+			pkg("upgraded", "x86_64", "0", "1.0", "1"),
+			pkg("downgraded", "x86_64", "0", "2.0", "1"),
+			pkg("epoch", "x86_64", "0", "3.0", "1"),
+			pkg("multi", "x86_64", "0", "1.0", "1"),
+			pkg("multi", "x86_64", "0", "1.1", "1"),
+		}
+		to := []repomd.RpmPackage{
+			pkg("same", "x86_64", "0", "1.0", "1"),
+			pkg("added", "noarch", "0", "1.0", "1"),
+			pkg("upgraded", "x86_64", "0", "1.0", "2"),
+			pkg("downgraded", "x86_64", "0", "1.0", "1"),
+			pkg("epoch", "x86_64", "1", "1.0", "1"),
+			pkg("multi", "x86_64", "0", "1.1", "1"),
+		}
+
+		It("should only return the packages with different versions, sorted by name", func() {
+			diffs := DiffPackages(from, to)
+
+			var changes []string
+			for _, d := range diffs {
+				changes = append(changes, d.Package+" "+d.Change)
+			}
+			Expect(changes).To(Equal([]string{
+				"added.noarch added",
+				"downgraded.x86_64 downgraded",
+				"epoch.x86_64 upgraded",
+				"multi.x86_64 changed",
+				"removed.noarch removed",
+				"upgraded.x86_64 upgraded",
+			}))
+		})
+
+		It("should hold the versions of each package list", func() {
+			diffs := DiffPackages(from, to)
+			Expect(diffs[3].Versions).To(Equal([][]repomd.EVR{
+				{evr("0", "1.0", "1"), evr("0", "1.1", "1")},
+				{evr("0", "1.1", "1")},
+			}))
+		})
+
+		It("should classify from the first to the last package list", func() {
+			diffs := DiffPackages(from, to, from)
+
+			var changes []string
+			for _, d := range diffs {
+				changes = append(changes, d.Package+" "+d.Change)
+			}
+			Expect(changes).To(ContainElement("upgraded.x86_64 changed"))
+			Expect(changes).To(ContainElement("added.noarch changed"))
+		})
+
+		It("should return nothing for the same package lists", func() {
+			Expect(DiffPackages(from, from)).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Problems reported by Fsck.
+const (
+	FsckNotRevision        = "not a revision"
+	FsckInvalidRevision    = "invalid revision"
+	FsckInvalidFile        = "invalid file"
+	FsckMissingRepomd      = "missing repomd.xml"
+	FsckDanglingTag        = "dangling tag"
+	FsckUnreadableRevision = "revision unreadable"
+	FsckForeignTag         = "foreign tag"
+	FsckPartialFile        = "partial file"
+)
+
+// lostFoundDir is the directory under the tmp dir where Fsck moves the
+// directories which aren't revisions.
+const lostFoundDir = "lost+found"
+
+// A FsckProblem describes a problem with the state on disk of a
+// repository. Fix describes the safe fix Fsck applies, it's empty when
+// the problem should be fixed by hand.
+type FsckProblem struct {
+	Path    string
+	Problem string
+	Detail  string
+	Fix     string
+	Fixed   bool
+}
+
+// The Fsck method checks the state on disk of the repository and returns
+// the problems found, ordered by path. Those are the directories in the
+// metadata dir which aren't revisions, revisions which can't be loaded or
+// have no repomd.xml, manifest, annotations and lock files which can't be
+// loaded, tags of which the target is gone, can't be loaded or is outside
+// the metadata dir and partial files left behind by interrupted downloads.
+//
+// When fix is true the safe fixes are applied: directories which aren't
+// revisions are moved to the lost+found dir under the tmp dir, untagged
+// and unlocked revisions without repomd.xml are deleted, dangling and
+// foreign tags are removed and partial files are deleted. Tags pointing
+// to a revision which can't be loaded are left alone, the revision has to
+// be repaired by hand. Removed tags are recorded in the tag journal with
+// the revision they pointed to, so they can be rolled back. Fsck should
+// not be run with fix while the repository is updated.
+func (r *Repository) Fsck(fix bool) ([]FsckProblem, error) {
+	r.initState()
+
+	problems := append([]FsckProblem{}, r.problems...)
+
+	for _, rev := range r.Revisions {
+		if _, err := os.Stat(r.getRevisionDir(rev) + repoXMLfile); os.IsNotExist(err) {
+			p := FsckProblem{Path: r.getRevisionDir(rev), Problem: FsckMissingRepomd}
+			if len(rev.Tags) > 0 {
+				p.Detail = "tagged " + strings.Join(rev.TagNames(), ", ")
+			}
+			problems = append(problems, p)
+		}
+	}
+
+	partial, err := r.getPartialFiles()
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, partial...)
+
+	for i := range problems {
+		problems[i].Fix = r.fsckFix(&problems[i])
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+
+	if !fix {
+		return problems, nil
+	}
+
+	for i, p := range problems {
+		if p.Fix == "" {
+			continue
+		}
+
+		if err := r.applyFsckFix(p); err != nil {
+			problems[i].Detail = "fix failed: " + err.Error()
+			continue
+		}
+		problems[i].Fixed = true
+	}
+
+	r.initState()
+	return problems, nil
+}
+
+// fsckFix returns the description of the safe fix of a problem, an empty
+// string when there is none.
+func (r *Repository) fsckFix(p *FsckProblem) string {
+	switch p.Problem {
+	case FsckNotRevision:
+		return "move to " + filepath.Join(r.ContentTmpPath, lostFoundDir)
+	case FsckMissingRepomd:
+		rev := r.revisionByDir(p.Path)
+		if rev == nil || len(rev.Tags) > 0 || rev.IsLocked() {
+			return ""
+		}
+		return "delete revision"
+	case FsckDanglingTag, FsckForeignTag:
+		return "remove tag"
+	case FsckPartialFile:
+		return "delete"
+	}
+	return ""
+}
+
+// applyFsckFix applies the safe fix of a problem.
+func (r *Repository) applyFsckFix(p FsckProblem) error {
+	switch p.Problem {
+	case FsckNotRevision:
+		dir := filepath.Join(r.ContentTmpPath, lostFoundDir)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+		return os.Rename(p.Path, filepath.Join(dir, filepath.Base(p.Path)))
+	case FsckMissingRepomd:
+		return os.RemoveAll(p.Path)
+	case FsckDanglingTag, FsckForeignTag:
+		// the revision the tag pointed to, for a rollback of the removal
+		var previous *Revision
+		if target, err := os.Readlink(p.Path); err == nil {
+			if id, err := strconv.ParseInt(filepath.Base(target), 10, 64); err == nil {
+				previous = &Revision{Id: id}
+			}
+		}
+
+		if err := os.Remove(p.Path); err != nil {
+			return err
+		}
+		tagname := tagNameFromFile(filepath.Base(p.Path))
+		if !r.isValidTagName(tagname) {
+			return nil
+		}
+		return r.recordTagChange(tagname, TagDeleted, previous, nil, "removed by fsck, "+p.Detail)
+	case FsckPartialFile:
+		return os.RemoveAll(p.Path)
+	}
+	return nil
+}
+
+// getPartialFiles returns the partial files of interrupted downloads in
+// the files and tmp dirs and of interrupted package index writes in the
+// metadata dir, and the revision and staging directories left behind in
+// the tmp dir by interrupted revision creations and imports.
+func (r *Repository) getPartialFiles() ([]FsckProblem, error) {
+	var problems []FsckProblem
+
+	// The walked paths are clean, the configured paths not always.
+	tmpPath := filepath.Clean(r.ContentTmpPath)
+
+	for _, dir := range []string{r.ContentFilesPath, r.ContentMDPath, tmpPath} {
+		err := filepath.Walk(filepath.Clean(dir), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() && path == filepath.Join(tmpPath, lostFoundDir) {
+				return filepath.SkipDir
+			}
+
+			if info.IsDir() && filepath.Dir(path) == tmpPath {
+				switch {
+				case strings.HasPrefix(info.Name(), "revision"):
+					problems = append(problems, FsckProblem{Path: path, Problem: FsckPartialFile, Detail: "revision directory"})
+					return filepath.SkipDir
+				case strings.HasPrefix(info.Name(), "import"):
+					problems = append(problems, FsckProblem{Path: path, Problem: FsckPartialFile, Detail: "import staging directory"})
+					return filepath.SkipDir
+				}
+			}
+
+			if info.Mode().IsRegular() && strings.HasSuffix(path, tmpSuffix) {
+				problems = append(problems, FsckProblem{Path: path, Problem: FsckPartialFile})
+			}
+			return nil
+		})
+
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	return problems, nil
+}
+
+// revisionByDir returns the revision of a revision directory. The
+// returned Revision will be nil when not found.
+func (r *Repository) revisionByDir(dir string) *Revision {
+	for _, rev := range r.Revisions {
+		if r.getRevisionDir(rev) == dir {
+			return rev
+		}
+	}
+	return nil
+}
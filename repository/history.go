@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"github.com/catay/rrst/repository/repomd"
+	"sort"
+)
+
+// A PackageHistory tells in which revisions a version of a package was
+// present. Added is the first revision holding the version and Removed the
+// first revision after it without, nil when the version is still present
+// in the latest revision. Tags holds the tags of the revisions in between,
+// those are the tags currently carrying the version. A version which got
+// removed and added again has a PackageHistory for each period.
+type PackageHistory struct {
+	Package string
+	Name    string
+	Arch    string
+	Version repomd.EVR
+	Added   *Revision
+	Removed *Revision
+	Tags    []string
+}
+
+// The History method walks all revisions in order and returns the history
+// of the versions of a package, ordered by the revision they got added in
+// and by version. The arch can be empty to include all architectures.
+// Revisions of which the package list can't be read are skipped, they
+// don't end the period a version is present in. An error is returned when
+// none of the revisions can be read.
+func (r *Repository) History(name string, arch string) ([]PackageHistory, error) {
+	var history []PackageHistory
+	var lastErr error
+	var read int
+
+	// the index in history of the current period of each version
+	present := make(map[string]int)
+
+	for _, rev := range r.Revisions {
+		packages, err := r.getPackageIndex(rev)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		read++
+
+		seen := make(map[string]bool)
+		for _, p := range packages {
+			if p.Name != name || (arch != "" && p.Arch != arch) {
+				continue
+			}
+
+			key := p.Arch + "-" + p.Version.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			i, ok := present[key]
+			if !ok {
+				history = append(history, PackageHistory{
+					Package: p.Name + "." + p.Arch,
+					Name:    p.Name,
+					Arch:    p.Arch,
+					Version: p.Version,
+					Added:   rev,
+				})
+				i = len(history) - 1
+				present[key] = i
+			}
+			history[i].Tags = append(history[i].Tags, rev.TagNames()...)
+		}
+
+		for key, i := range present {
+			if !seen[key] {
+				history[i].Removed = rev
+				delete(present, key)
+			}
+		}
+	}
+
+	if read == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	for i := range history {
+		sort.Strings(history[i].Tags)
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		a, b := history[i], history[j]
+		if a.Added.Id != b.Added.Id {
+			return a.Added.Id < b.Added.Id
+		}
+		if c := repomd.CompareEVR(a.Version, b.Version); c != 0 {
+			return c < 0
+		}
+		return a.Arch < b.Arch
+	})
+
+	return history, nil
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"encoding/gob"
+	"fmt"
+	"github.com/catay/rrst/repository/repomd"
+	"os"
+)
+
+const (
+	packageIndexFile = "/packages.idx"
+
+	// packageIndexVersion is raised when the format of the package index
+	// changes, which makes the existing indexes stale.
+	packageIndexVersion = 1
+)
+
+// A packageIndex is the compact package list of a revision, stored in gob
+// format in the revision dir. It saves reading the whole primary metadata
+// for queries which only need the names and versions of the packages.
+// Primary holds the checksum of the primary metadata the index was built
+// from, the index is stale when it no longer matches the one in
+// repomd.xml.
+type packageIndex struct {
+	Version  int
+	Primary  string
+	Packages []indexedPackage
+}
+
+// An indexedPackage holds the fields of a package kept in the index.
+type indexedPackage struct {
+	Name     string
+	Arch     string
+	Epoch    string
+	Ver      string
+	Rel      string
+	Size     int64
+	Location string
+}
+
+// The PackageIndex method returns the packages of a tag or revision from
+// the package index. Those only hold the name, arch, version, package size
+// and location, use Packages when the dependencies are needed.
+func (r *Repository) PackageIndex(tagOrRev string) ([]repomd.RpmPackage, error) {
+	rev := r.revisionByTagOrRevId(tagOrRev)
+	if rev == nil {
+		return nil, fmt.Errorf("tag or revision %s not found", tagOrRev)
+	}
+
+	return r.getPackageIndex(rev)
+}
+
+// getPackageIndex returns the packages of a revision from its package
+// index. The index is built from the primary metadata when missing or
+// stale, reading one package at a time. Failing to save it isn't an
+// error, as the content path can be read-only for the user.
+func (r *Repository) getPackageIndex(rev *Revision) ([]repomd.RpmPackage, error) {
+	rm, err := r.getLocalMetadata(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	var primary string
+	for _, v := range rm.Data {
+		if v.Type == "primary" {
+			primary = v.CheckSum.Type + ":" + v.CheckSum.Value
+		}
+	}
+
+	if idx, err := loadPackageIndex(r.getPackageIndexPath(rev)); err == nil && idx.Version == packageIndexVersion && idx.Primary == primary {
+		return idx.packages(), nil
+	}
+
+	idx := &packageIndex{Version: packageIndexVersion, Primary: primary}
+	err = r.walkMetadataPackages(rev, func(p *repomd.RpmPackage) error {
+		idx.add(p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	idx.save(r.getPackageIndexPath(rev))
+
+	return idx.packages(), nil
+}
+
+// getPackageIndexPath returns the path of the package index file of a
+// revision.
+func (r *Repository) getPackageIndexPath(rev *Revision) string {
+	return r.getRevisionDir(rev) + packageIndexFile
+}
+
+// add adds a package to the index.
+func (idx *packageIndex) add(p *repomd.RpmPackage) {
+	idx.Packages = append(idx.Packages, indexedPackage{
+		Name:     p.Name,
+		Arch:     p.Arch,
+		Epoch:    p.Version.Epoch,
+		Ver:      p.Version.Ver,
+		Rel:      p.Version.Rel,
+		Size:     p.Size.Package,
+		Location: p.Location.Path,
+	})
+}
+
+// loadPackageIndex loads a package index file.
+func loadPackageIndex(name string) (*packageIndex, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	idx := &packageIndex{}
+	if err := gob.NewDecoder(f).Decode(idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// save writes the package index to a file. It's written to a partial file
+// first and renamed, so concurrent readers never see a partial index.
+func (idx *packageIndex) save(name string) error {
+	f, err := os.Create(name + tmpSuffix)
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(f).Encode(idx); err != nil {
+		f.Close()
+		os.Remove(name + tmpSuffix)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(name + tmpSuffix)
+		return err
+	}
+
+	return os.Rename(name+tmpSuffix, name)
+}
+
+// packages returns the indexed packages.
+func (idx *packageIndex) packages() []repomd.RpmPackage {
+	packages := make([]repomd.RpmPackage, 0, len(idx.Packages))
+	for _, v := range idx.Packages {
+		p := repomd.RpmPackage{
+			Name:    v.Name,
+			Arch:    v.Arch,
+			Version: repomd.EVR{Epoch: v.Epoch, Ver: v.Ver, Rel: v.Rel},
+		}
+		p.Size.Package = v.Size
+		p.Location.Path = v.Location
+		packages = append(packages, p)
+	}
+	return packages
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+)
+
+const (
+	tagJournalFile = "/tags.journal"
+)
+
+// Tag change actions recorded in the tag journal.
+const (
+	TagCreated = "create"
+	TagMoved   = "move"
+	TagDeleted = "delete"
+)
+
+// A TagChange records a single change of a tag in the append-only tag
+// journal of a repository. Previous is 0 when the tag got created and
+// Revision is 0 when the tag got deleted.
+type TagChange struct {
+	Time     time.Time `json:"time"`
+	User     string    `json:"user"`
+	Tag      string    `json:"tag"`
+	Action   string    `json:"action"`
+	Previous int64     `json:"previous,omitempty"`
+	Revision int64     `json:"revision,omitempty"`
+	Comment  string    `json:"comment,omitempty"`
+}
+
+// The TagHistory method returns the recorded changes of a tag, oldest
+// first. All tag changes are returned when the tag name is empty.
+func (r *Repository) TagHistory(tagname string) ([]TagChange, error) {
+	var changes []TagChange
+
+	f, err := os.Open(r.getTagJournalPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return changes, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var c TagChange
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			return nil, fmt.Errorf("tag journal corrupt: %s", err)
+		}
+
+		if tagname == "" || c.Tag == tagname {
+			changes = append(changes, c)
+		}
+	}
+
+	return changes, scanner.Err()
+}
+
+// The RollbackTag method restores the revision a tag pointed to before
+// its last steps changes. Rolling back a tag creation deletes the tag.
+// The rollback itself is recorded as a tag change, so rolling back one
+// step twice returns to the original revision.
+func (r *Repository) RollbackTag(tagname string, steps int) (bool, error) {
+	if steps < 1 {
+		return false, fmt.Errorf("steps should be at least 1")
+	}
+
+	changes, err := r.TagHistory(tagname)
+	if err != nil {
+		return false, err
+	}
+
+	if steps > len(changes) {
+		return false, fmt.Errorf("Tag %v has only %v recorded changes.", tagname, len(changes))
+	}
+
+	target := changes[len(changes)-steps].Previous
+	comment := fmt.Sprintf("rollback %v step(s)", steps)
+
+	if target == 0 {
+		return r.deleteTag(tagname, comment)
+	}
+
+	if r.revisionById(target) == nil {
+		return false, fmt.Errorf("Revision %v of tag %v no longer exists.", target, tagname)
+	}
+
+	return r.tag(tagname, target, comment)
+}
+
+// recordTagChange appends a tag change to the tag journal.
+func (r *Repository) recordTagChange(tagname string, action string, previous *Revision, rev *Revision, comment string) error {
+	c := TagChange{
+		Time:    time.Now(),
+		User:    currentUser(),
+		Tag:     tagname,
+		Action:  action,
+		Comment: comment,
+	}
+
+	if previous != nil {
+		c.Previous = previous.Id
+	}
+
+	if rev != nil {
+		c.Revision = rev.Id
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(r.getTagJournalPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("tag journal: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("tag journal: %s", err)
+	}
+
+	return f.Close()
+}
+
+// getTagJournalPath returns the path of the tag journal.
+func (r *Repository) getTagJournalPath() string {
+	return r.ContentMDPath + tagJournalFile
+}
+
+// currentUser returns the name of the user running rrst. The invoking
+// user is returned when running through sudo.
+func currentUser() string {
+	if v := os.Getenv("SUDO_USER"); v != "" {
+		return v
+	}
+
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return "unknown"
+}
@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	lockFile = "/lock.yaml"
+)
+
+// ErrPackageLocked is returned when a package file would be written with
+// other content than recorded in the lock of a revision referencing it.
+var ErrPackageLocked = errors.New("package referenced by a locked revision with different content")
+
+// A Lock freezes the content of a revision. It records the SHA-256
+// checksums of the repodata files of the revision and of the packages it
+// references, so the content can be verified later on. Package files are
+// never overwritten, and a package file referenced by a locked revision
+// is only written again, after it went missing, with the locked content.
+type Lock struct {
+	Time     time.Time         `yaml:"time"`
+	User     string            `yaml:"user"`
+	Metadata map[string]string `yaml:"metadata"`
+	Packages map[string]string `yaml:"packages"`
+}
+
+// A LockMismatch describes a file of a locked revision which is missing
+// or changed since the revision got locked.
+type LockMismatch struct {
+	Path    string
+	Problem string
+}
+
+// NewLockFromFile returns a Lock loaded from a lock file.
+func NewLockFromFile(name string) (*Lock, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Lock{}
+	if err := yaml.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return l, nil
+}
+
+// Save writes the lock to a file.
+func (l *Lock) Save(name string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, data, 0644)
+}
+
+// IsLocked returns true when the revision is locked.
+func (re *Revision) IsLocked() bool {
+	return re.Lock != nil
+}
+
+// The LockRevision method locks a revision, referenced by tag or revision
+// id. A locked revision can't be deleted or updated anymore. It returns
+// false when the revision was locked already.
+func (r *Repository) LockRevision(tagOrRev string) (bool, error) {
+	rev := r.revisionByTagOrRevId(tagOrRev)
+	if rev == nil {
+		return false, fmt.Errorf("tag or revision %s not found", tagOrRev)
+	}
+
+	if rev.IsLocked() {
+		return false, nil
+	}
+
+	l := &Lock{
+		Time:     time.Now(),
+		User:     currentUser(),
+		Metadata: make(map[string]string),
+		Packages: make(map[string]string),
+	}
+
+	metadata, err := r.getRepodataFiles(rev)
+	if err != nil {
+		return false, err
+	}
+
+	for _, v := range metadata {
+		sum, err := sha256File(r.getRevisionDir(rev) + "/" + v)
+		if err != nil {
+			return false, err
+		}
+		l.Metadata[v] = sum
+	}
+
+	packages, err := r.getMetadataPackageList(rev)
+	if err != nil {
+		return false, err
+	}
+
+	for _, p := range packages {
+		sum, err := sha256File(r.ContentFilesPath + "/" + p.Location.Path)
+		if err != nil {
+			return false, fmt.Errorf("revision %v is incomplete: %s", rev.Id, err)
+		}
+		l.Packages[p.Location.Path] = sum
+	}
+
+	if err := l.Save(r.getLockPath(rev)); err != nil {
+		return false, err
+	}
+
+	rev.Lock = l
+	return true, nil
+}
+
+// The UnlockRevision method removes the lock of a revision, referenced by
+// tag or revision id. It returns false when the revision wasn't locked.
+func (r *Repository) UnlockRevision(tagOrRev string) (bool, error) {
+	rev := r.revisionByTagOrRevId(tagOrRev)
+	if rev == nil {
+		return false, fmt.Errorf("tag or revision %s not found", tagOrRev)
+	}
+
+	if !rev.IsLocked() {
+		return false, nil
+	}
+
+	if err := os.Remove(r.getLockPath(rev)); err != nil {
+		return false, err
+	}
+
+	rev.Lock = nil
+	return true, nil
+}
+
+// The VerifyLock method checks if the content of a locked revision still
+// matches the checksums recorded when it got locked. The mismatches are
+// returned sorted by path.
+func (r *Repository) VerifyLock(rev *Revision) ([]LockMismatch, error) {
+	var mismatches []LockMismatch
+
+	if !rev.IsLocked() {
+		return nil, fmt.Errorf("revision %v is not locked", rev.Id)
+	}
+
+	check := func(path string, name string, sum string) {
+		current, err := sha256File(name)
+		switch {
+		case os.IsNotExist(err):
+			mismatches = append(mismatches, LockMismatch{path, "missing"})
+		case err != nil:
+			mismatches = append(mismatches, LockMismatch{path, err.Error()})
+		case current != sum:
+			mismatches = append(mismatches, LockMismatch{path, "modified"})
+		}
+	}
+
+	for k, v := range rev.Lock.Metadata {
+		check(k, r.getRevisionDir(rev)+"/"+k, v)
+	}
+
+	for k, v := range rev.Lock.Packages {
+		check(k, r.ContentFilesPath+"/"+k, v)
+	}
+
+	// Metadata files added after locking change the revision content too.
+	metadata, err := r.getRepodataFiles(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range metadata {
+		if _, ok := rev.Lock.Metadata[v]; !ok {
+			mismatches = append(mismatches, LockMismatch{v, "added"})
+		}
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Path < mismatches[j].Path
+	})
+
+	return mismatches, nil
+}
+
+// The LockedRevisions method returns the locked revisions.
+func (r *Repository) LockedRevisions() []*Revision {
+	var revisions []*Revision
+	for _, rev := range r.Revisions {
+		if rev.IsLocked() {
+			revisions = append(revisions, rev)
+		}
+	}
+	return revisions
+}
+
+// checkLockedPackage returns ErrPackageLocked when a package file, with
+// the path relative to the files dir, is referenced by a locked revision
+// and the file at name, which is about to take its place, has other
+// content than recorded in the lock.
+func (r *Repository) checkLockedPackage(path string, name string) error {
+	for _, rev := range r.LockedRevisions() {
+		sum, ok := rev.Lock.Packages[path]
+		if !ok {
+			continue
+		}
+
+		current, err := sha256File(name)
+		if err != nil {
+			return err
+		}
+		if current != sum {
+			return ErrPackageLocked
+		}
+		return nil
+	}
+	return nil
+}
+
+// getRepodataFiles returns the repodata files of a revision, relative
+// to the revision directory.
+func (r *Repository) getRepodataFiles(rev *Revision) ([]string, error) {
+	var files []string
+
+	dir := r.getRevisionDir(rev)
+	err := filepath.Walk(dir+"/repodata", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+// getLockPath returns the path of the lock file of a revision.
+func (r *Repository) getLockPath(rev *Revision) string {
+	return r.getRevisionDir(rev) + lockFile
+}
+
+// loadLock loads the lock of a revision when present.
+func (r *Repository) loadLock(rev *Revision) error {
+	l, err := NewLockFromFile(r.getLockPath(rev))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	rev.Lock = l
+	return nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/catay/rrst/version"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const (
+	manifestFile = "/manifest.yaml"
+)
+
+// A Manifest records where the content of a revision came from.
+//
+// Downloaded only counts the package bytes fetched during the sync, not
+// the packages which were already present in the files directory.
+// Filters lists the package filters applied during the sync, if any.
+type Manifest struct {
+	Source            string    `yaml:"source" json:"source"`
+	UpstreamRevision  string    `yaml:"upstream_revision" json:"upstream_revision"`
+	UpstreamTimestamp time.Time `yaml:"upstream_timestamp,omitempty" json:"upstream_timestamp,omitempty"`
+	Packages          int       `yaml:"packages" json:"packages"`
+	TotalSize         int64     `yaml:"total_size" json:"total_size"`
+	Downloaded        int64     `yaml:"downloaded" json:"downloaded"`
+	SyncDuration      string    `yaml:"sync_duration" json:"sync_duration"`
+	SyncedAt          time.Time `yaml:"synced_at" json:"synced_at"`
+	RrstVersion       string    `yaml:"rrst_version" json:"rrst_version"`
+	Filters           []string  `yaml:"filters,omitempty" json:"filters,omitempty"`
+}
+
+// NewManifestFromFile returns a Manifest loaded from a manifest file.
+func NewManifestFromFile(name string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return m, nil
+}
+
+// Save writes the manifest to a file.
+func (m *Manifest) Save(name string) error {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, data, 0644)
+}
+
+// AddSync accounts the downloaded bytes and duration of a sync run.
+// A revision can be synced in multiple runs when a sync gets interrupted.
+func (m *Manifest) AddSync(downloaded int64, d time.Duration) {
+	previous, _ := time.ParseDuration(m.SyncDuration)
+	m.Downloaded += downloaded
+	m.SyncDuration = (previous + d).Round(time.Millisecond).String()
+	m.SyncedAt = time.Now()
+	m.RrstVersion = version.FullVersionString
+}
+
+// getManifestPath returns the path of the manifest file of a revision.
+func (r *Repository) getManifestPath(rev *Revision) string {
+	return r.getRevisionDir(rev) + manifestFile
+}
+
+// loadManifest loads the manifest of a revision when present.
+func (r *Repository) loadManifest(rev *Revision) error {
+	m, err := NewManifestFromFile(r.getManifestPath(rev))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	rev.Manifest = m
+	return nil
+}
+
+// writeManifest records the provenance of a revision after a sync from
+// the source URL. An existing manifest is only updated when packages got
+// downloaded, which is the case when an interrupted sync gets resumed.
+func (r *Repository) writeManifest(rev *Revision, source string, downloaded int64, d time.Duration) error {
+	if rev.Manifest != nil && downloaded == 0 {
+		return nil
+	}
+
+	if rev.Manifest == nil {
+		m, err := r.newManifest(rev, source)
+		if err != nil {
+			return err
+		}
+		rev.Manifest = m
+	}
+
+	rev.Manifest.AddSync(downloaded, d)
+	return rev.Manifest.Save(r.getManifestPath(rev))
+}
+
+// newManifest returns a new Manifest with the source and package
+// statistics of a revision.
+func (r *Repository) newManifest(rev *Revision, source string) (*Manifest, error) {
+	m := &Manifest{
+		Source: source,
+	}
+
+	rm, err := r.getLocalMetadata(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	m.UpstreamRevision = rm.Revision
+	if ts := rm.Timestamp(); ts > 0 {
+		m.UpstreamTimestamp = time.Unix(ts, 0)
+	}
+
+	packages, err := r.getPackageIndex(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Packages = len(packages)
+	for _, p := range packages {
+		m.TotalSize += p.Size.Package
+	}
+
+	return m, nil
+}
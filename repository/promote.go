@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/catay/rrst/config"
+	"time"
+)
+
+// A Promotion describes the move of a pipeline stage tag to the revision
+// of the source tag, the previous stage or latest for the first stage.
+type Promotion struct {
+	Stage    string
+	Source   string
+	Revision *Revision
+}
+
+// The NewPromotion method returns the promotion of a pipeline stage after
+// checking the pipeline rules. The previous stage has to exist, all the
+// earlier stages should have seen the revision already and the revision
+// has to be in the previous stage for at least the minimum soak time.
+// When force is true, missing stages are skipped and the rules are not
+// enforced. Revisions labelled blocked are refused in any case.
+func (r *Repository) NewPromotion(stage string, force bool) (*Promotion, error) {
+	if r.Pipeline == nil {
+		return nil, fmt.Errorf("no pipeline configured for repository %s", r.Name)
+	}
+
+	idx, ok := r.Pipeline.StageIndex(stage)
+	if !ok {
+		return nil, fmt.Errorf("stage %s not part of pipeline %s", stage, r.Pipeline.Id)
+	}
+
+	// Find the source tag. Only when forced, missing stages are skipped.
+	p := &Promotion{Stage: stage}
+	for i := idx - 1; i >= -1; i-- {
+		source := config.DefaultLatestRevisionTag
+		if i >= 0 {
+			source = r.Pipeline.Stages[i]
+		}
+
+		if tag := r.tagByName(source); tag != nil {
+			p.Source = source
+			p.Revision = tag.Revision
+			break
+		}
+
+		if !force {
+			return nil, fmt.Errorf("stage %s has no revision yet, stages can't be skipped unless forced", source)
+		}
+	}
+
+	if p.Revision == nil {
+		return nil, fmt.Errorf("no revision found to promote to stage %s", stage)
+	}
+
+	// A blocked revision is never promoted, not even when forced.
+	if p.Revision.Annotations.HasLabel(BlockedLabel, "") {
+		return nil, fmt.Errorf("revision %v is labelled %s and can't be promoted", p.Revision.Id, BlockedLabel)
+	}
+
+	if force {
+		return p, nil
+	}
+
+	// The earlier stages should have seen the revision, which means they
+	// point to the same or a more recent revision.
+	for _, s := range r.Pipeline.Stages[:idx] {
+		tag := r.tagByName(s)
+		if tag == nil || tag.Revision.Id < p.Revision.Id {
+			return nil, fmt.Errorf("revision %v did not pass stage %s yet", p.Revision.Id, s)
+		}
+	}
+
+	if soak := r.Pipeline.MinSoak; soak > 0 {
+		since, err := r.taggedSince(p.Source)
+		if err != nil {
+			return nil, err
+		}
+
+		if elapsed := time.Since(since); elapsed < soak {
+			return nil, fmt.Errorf("revision %v is in %s for %v, the minimum soak time is %v",
+				p.Revision.Id, p.Source, elapsed.Round(time.Minute), soak)
+		}
+	}
+
+	return p, nil
+}
+
+// The Promote method moves the stage tag to the revision of the promotion.
+func (r *Repository) Promote(p *Promotion) (bool, error) {
+	return r.tag(p.Stage, p.Revision.Id, "promoted from "+p.Source)
+}
+
+// taggedSince returns the time a tag got linked to its current revision.
+// It falls back to the revision creation time when the change is not
+// recorded in the tag journal.
+func (r *Repository) taggedSince(tagname string) (time.Time, error) {
+	tag := r.tagByName(tagname)
+	if tag == nil {
+		return time.Time{}, fmt.Errorf("Tag %v not found.", tagname)
+	}
+
+	changes, err := r.TagHistory(tagname)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if n := len(changes); n > 0 && changes[n-1].Revision == tag.Revision.Id {
+		return changes[n-1].Time, nil
+	}
+
+	return tag.Revision.Created, nil
+}
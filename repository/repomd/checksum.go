@@ -0,0 +1,52 @@
+package repomd
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// NewHash returns the hash for a repomd checksum type, like sha256.
+// The type sha is an alias for sha1, as used by older createrepo releases.
+func NewHash(checksumType string) (hash.Hash, error) {
+	switch checksumType {
+	case "md5":
+		return md5.New(), nil
+	case "sha", "sha1":
+		return sha1.New(), nil
+	case "sha224":
+		return sha256.New224(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha384":
+		return sha512.New384(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("checksum type %s not supported", checksumType)
+}
+
+// FileChecksum returns the hex encoded checksum of a file for a repomd
+// checksum type.
+func FileChecksum(checksumType string, name string) (string, error) {
+	h, err := NewHash(checksumType)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
@@ -0,0 +1,52 @@
+package repomd_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/catay/rrst/repository/repomd"
+)
+
+var _ = Describe("Checksum", func() {
+
+	Describe("Given a function FileChecksum(checksumType, name string)", func() {
+		var name string
+
+		BeforeEach(func() {
+			f, err := ioutil.TempFile("", "checksum")
+			Expect(err).NotTo(HaveOccurred())
+			f.WriteString("rrst")
+			f.Close()
+			name = f.Name()
+		})
+
+		AfterEach(func() {
+			os.Remove(name)
+		})
+
+		cases := []struct {
+			checksumType, sum string
+		}{
+			{"md5", "480b3fa2d679840798f74a04a6c7c705"},
+			{"sha", "e8313c54be5673bdfab91cfb4fe6f5dd445bf4d5"},
+			{"sha256", "54221d0837d9f6cedaf862d97c4961de2c56f48f2eaebf48ec69cac9f20c855a"},
+		}
+
+		for _, c := range cases {
+			c := c
+			It("should return the "+c.checksumType+" checksum of the file", func() {
+				sum, err := FileChecksum(c.checksumType, name)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(sum).To(Equal(c.sum))
+			})
+		}
+
+		It("should refuse an unknown checksum type", func() {
+			_, err := FileChecksum("crc32", name)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
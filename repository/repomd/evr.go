@@ -0,0 +1,166 @@
+package repomd
+
+import (
+	"strings"
+)
+
+// EVR holds the epoch, version and release of a package or of a
+// versioned dependency.
+type EVR struct {
+	Epoch string `xml:"epoch,attr"`
+	Ver   string `xml:"ver,attr"`
+	Rel   string `xml:"rel,attr"`
+}
+
+// String returns the EVR formatted as [epoch:]version[-release].
+// The epoch is omitted when empty or zero.
+func (e EVR) String() string {
+	s := e.Ver
+	if e.Rel != "" {
+		s += "-" + e.Rel
+	}
+	if e.Epoch != "" && e.Epoch != "0" {
+		s = e.Epoch + ":" + s
+	}
+	return s
+}
+
+// CompareEVR compares two EVR's the way rpm does.
+// It returns 0 when equal, 1 when a is newer and -1 when b is newer.
+// A missing epoch is treated as zero. The release is only compared when
+// set on both sides, so a version only dependency matches any release.
+func CompareEVR(a, b EVR) int {
+	if rc := Vercmp(epochOrZero(a.Epoch), epochOrZero(b.Epoch)); rc != 0 {
+		return rc
+	}
+
+	if rc := Vercmp(a.Ver, b.Ver); rc != 0 {
+		return rc
+	}
+
+	if a.Rel == "" || b.Rel == "" {
+		return 0
+	}
+
+	return Vercmp(a.Rel, b.Rel)
+}
+
+// Vercmp compares two version or release strings with the rpmvercmp
+// algorithm. It returns 0 when equal, 1 when a is newer and -1 when b is
+// newer.
+func Vercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	for len(a) > 0 || len(b) > 0 {
+		a = strings.TrimLeftFunc(a, isSeparator)
+		b = strings.TrimLeftFunc(b, isSeparator)
+
+		// A tilde sorts before everything, even the end of a string.
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		// A caret sorts after the end of a string, but before anything else.
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			if a == "" {
+				return -1
+			}
+			if b == "" {
+				return 1
+			}
+			if !strings.HasPrefix(a, "^") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "^") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if a == "" || b == "" {
+			break
+		}
+
+		var segA, segB string
+		isNum := isDigit(rune(a[0]))
+		if isNum {
+			segA, a = splitSegment(a, isDigit)
+			segB, b = splitSegment(b, isDigit)
+		} else {
+			segA, a = splitSegment(a, isAlpha)
+			segB, b = splitSegment(b, isAlpha)
+		}
+
+		// A numeric segment is always newer than an alpha segment.
+		if segB == "" {
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+
+		if isNum {
+			segA = strings.TrimLeft(segA, "0")
+			segB = strings.TrimLeft(segB, "0")
+			if len(segA) > len(segB) {
+				return 1
+			}
+			if len(segB) > len(segA) {
+				return -1
+			}
+		}
+
+		if rc := strings.Compare(segA, segB); rc != 0 {
+			return rc
+		}
+	}
+
+	if a == "" && b == "" {
+		return 0
+	}
+	if a == "" {
+		return -1
+	}
+	return 1
+}
+
+// epochOrZero returns the epoch or 0 when empty.
+func epochOrZero(epoch string) string {
+	if epoch == "" {
+		return "0"
+	}
+	return epoch
+}
+
+// splitSegment splits the leading characters matching f off s.
+func splitSegment(s string, f func(rune) bool) (string, string) {
+	i := strings.IndexFunc(s, func(r rune) bool { return !f(r) })
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isAlpha(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// isSeparator returns true for characters which are not part of a
+// version segment.
+func isSeparator(r rune) bool {
+	return !isDigit(r) && !isAlpha(r) && r != '~' && r != '^'
+}
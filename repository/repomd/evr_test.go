@@ -0,0 +1,84 @@
+package repomd_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/catay/rrst/repository/repomd"
+)
+
+var _ = Describe("EVR", func() {
+
+	Describe("Given a function Vercmp(a, b string)", func() {
+		// Test cases taken from the rpm test suite (rpmvercmp.at).
+		cases := []struct {
+			a, b string
+			rc   int
+		}{
+			{"1.0", "1.0", 0},
+			{"1.0", "2.0", -1},
+			{"2.0", "1.0", 1},
+			{"2.0.1", "2.0.1", 0},
+			{"2.0", "2.0.1", -1},
+			{"2.0.1a", "2.0.1", 1},
+			{"5.5p1", "5.5p10", -1},
+			{"10xyz", "10.1xyz", -1},
+			{"xyz10", "xyz10.1", -1},
+			{"1.0aa", "1.0a", 1},
+			{"10.0001", "10.1", 0},
+			{"10.0001", "10.0039", -1},
+			{"4.999.9", "5.0", -1},
+			{"20101121", "20101122", -1},
+			{"2_0", "2_0", 0},
+			{"2.0", "2_0", 0},
+			{"a", "a", 0},
+			{"a+", "a_", 0},
+			{"+", "_", 0},
+			{"1.0~rc1", "1.0", -1},
+			{"1.0~rc1", "1.0~rc2", -1},
+			{"1.0~rc1~git123", "1.0~rc1", -1},
+			{"1.0^", "1.0", 1},
+			{"1.0^git1", "1.0.1", -1},
+			{"1.0^git1~pre", "1.0^git1", -1},
+			{"1.0", "1.0a", -1},
+			{"1.0a", "1.0", 1},
+		}
+
+		for _, c := range cases {
+			c := c
+			It("should compare "+c.a+" with "+c.b, func() {
+				Expect(Vercmp(c.a, c.b)).To(Equal(c.rc))
+			})
+		}
+	})
+
+	Describe("Given a function CompareEVR(a, b EVR)", func() {
+		Context("when the epochs differ", func() {
+			It("should let the epoch win over the version", func() {
+				Expect(CompareEVR(EVR{"1", "1.0", "1"}, EVR{"0", "2.0", "1"})).To(Equal(1))
+			})
+		})
+
+		Context("when an epoch is missing", func() {
+			It("should treat it as zero", func() {
+				Expect(CompareEVR(EVR{"", "1.0", "1"}, EVR{"0", "1.0", "1"})).To(Equal(0))
+			})
+		})
+
+		Context("when a release is missing", func() {
+			It("should only compare the versions", func() {
+				Expect(CompareEVR(EVR{"0", "1.0", ""}, EVR{"0", "1.0", "5"})).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("Given a method String()", func() {
+		It("should omit a zero epoch", func() {
+			Expect(EVR{"0", "1.0", "1"}.String()).To(Equal("1.0-1"))
+		})
+
+		It("should prefix a non zero epoch", func() {
+			Expect(EVR{"2", "1.0", "1"}.String()).To(Equal("2:1.0-1"))
+		})
+	})
+})
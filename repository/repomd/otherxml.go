@@ -0,0 +1,61 @@
+package repomd
+
+import (
+	"sort"
+	"time"
+)
+
+// OtherPackage holds the changelog entries of a single package. The
+// PkgId matches the checksum of the package in primary.xml.
+type OtherPackage struct {
+	PkgId     string           `xml:"pkgid,attr"`
+	Name      string           `xml:"name,attr"`
+	Arch      string           `xml:"arch,attr"`
+	Version   EVR              `xml:"version"`
+	Changelog []ChangelogEntry `xml:"changelog"`
+}
+
+// ChangelogEntry is a single changelog entry of a package. The author
+// usually ends with the version of the package the entry was added in,
+// the date is in Unix time.
+type ChangelogEntry struct {
+	Author string `xml:"author,attr" json:"author" yaml:"author"`
+	Date   int64  `xml:"date,attr" json:"date" yaml:"date"`
+	Text   string `xml:",chardata" json:"text" yaml:"text"`
+}
+
+// Time returns the date of the changelog entry.
+func (c ChangelogEntry) Time() time.Time {
+	return time.Unix(c.Date, 0).UTC()
+}
+
+// String returns the changelog entry formatted as rpm does, for example
+// "* Tue Nov 14 2023 John Doe <jd@example.com> - 1.0-2" followed by the
+// text on the next lines.
+func (c ChangelogEntry) String() string {
+	return "* " + c.Time().Format("Mon Jan 02 2006") + " " + c.Author + "\n" + c.Text
+}
+
+// ChangelogSince returns the changelog entries of a package which are not
+// part of the changelog of an older version of the package, newest first.
+// Entries are compared on date, author and text, so entries added on the
+// same day as the newest entry of the older version are kept.
+func ChangelogSince(older []ChangelogEntry, newer []ChangelogEntry) []ChangelogEntry {
+	seen := make(map[ChangelogEntry]bool, len(older))
+	for _, c := range older {
+		seen[c] = true
+	}
+
+	var entries []ChangelogEntry
+	for _, c := range newer {
+		if !seen[c] {
+			entries = append(entries, c)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date > entries[j].Date
+	})
+
+	return entries
+}
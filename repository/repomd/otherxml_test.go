@@ -0,0 +1,102 @@
+package repomd_test
+
+import (
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/catay/rrst/repository/repomd"
+)
+
+var _ = Describe("OtherXML", func() {
+
+	const otherXML = `<?xml version="1.0" encoding="UTF-8"?>
+<otherdata xmlns="http://linux.duke.edu/metadata/other" packages="2">
+<package pkgid="a1" name="foo" arch="x86_64">
+  <version epoch="0" ver="1.0" rel="1"/>
+  <changelog author="Jane Doe &lt;jane@example.com&gt; - 1.0-1" date="1699920000">- initial release</changelog>
+</package>
+<package pkgid="b2" name="foo" arch="x86_64">
+  <version epoch="0" ver="1.0" rel="2"/>
+  <changelog author="Jane Doe &lt;jane@example.com&gt; - 1.0-1" date="1699920000">- initial release</changelog>
+  <changelog author="John Doe &lt;john@example.com&gt; - 1.0-2" date="1700006400">- fix CVE-2023-1234</changelog>
+</package>
+</otherdata>`
+
+	Describe("Given a function NewOtherReader(r io.Reader)", func() {
+		It("should read the changelogs one package at a time", func() {
+			ot, err := NewOtherReader(strings.NewReader(otherXML))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ot.Packages).To(Equal(2))
+
+			_, err = ot.Next()
+			Expect(err).NotTo(HaveOccurred())
+
+			p, err := ot.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.PkgId).To(Equal("b2"))
+			Expect(p.Version).To(Equal(EVR{"0", "1.0", "2"}))
+			Expect(p.Changelog).To(HaveLen(2))
+			Expect(p.Changelog[1].Author).To(Equal("John Doe <john@example.com> - 1.0-2"))
+			Expect(p.Changelog[1].Text).To(Equal("- fix CVE-2023-1234"))
+
+			_, err = ot.Next()
+			Expect(err).To(Equal(io.EOF))
+		})
+
+		It("should fail on invalid XML", func() {
+			ot, err := NewOtherReader(strings.NewReader("<otherdata>"))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = ot.Next()
+			Expect(err).To(HaveOccurred())
+			Expect(err).NotTo(Equal(io.EOF))
+		})
+	})
+
+	Describe("Given a function ChangelogSince(older, newer []ChangelogEntry)", func() {
+		var older, newer *OtherPackage
+
+		BeforeEach(func() {
+			ot, err := NewOtherReader(strings.NewReader(otherXML))
+			Expect(err).NotTo(HaveOccurred())
+			older, _ = ot.Next()
+			newer, _ = ot.Next()
+		})
+
+		It("should only return the entries added after the older version", func() {
+			entries := ChangelogSince(older.Changelog, newer.Changelog)
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Text).To(Equal("- fix CVE-2023-1234"))
+		})
+
+		It("should return all entries newest first without older changelog", func() {
+			entries := ChangelogSince(nil, newer.Changelog)
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[0].Date).To(Equal(int64(1700006400)))
+		})
+
+		It("should return the entries added on the same day as the older version", func() {
+			older := []ChangelogEntry{
+				{Author: "John Doe <john@example.com> - 1.0-1", Date: 1700006400, Text: "- update to 1.0"},
+			}
+			newer := []ChangelogEntry{
+				{Author: "John Doe <john@example.com> - 1.0-2", Date: 1700006400, Text: "- fix build"},
+				{Author: "John Doe <john@example.com> - 1.0-1", Date: 1700006400, Text: "- update to 1.0"},
+			}
+
+			entries := ChangelogSince(older, newer)
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Text).To(Equal("- fix build"))
+		})
+	})
+
+	Describe("Given a method String()", func() {
+		It("should format the entry as rpm does", func() {
+			c := ChangelogEntry{Author: "John Doe <john@example.com> - 1.0-2", Date: 1700006400, Text: "- fix"}
+			Expect(c.String()).To(Equal("* Wed Nov 15 2023 John Doe <john@example.com> - 1.0-2\n- fix"))
+		})
+	})
+})
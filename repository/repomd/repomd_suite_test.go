@@ -0,0 +1,13 @@
+package repomd_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRepomd(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Repomd Suite")
+}
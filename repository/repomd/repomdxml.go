@@ -4,6 +4,8 @@ import (
 	"encoding/xml"
 	"io"
 	"io/ioutil"
+	"strconv"
+	"strings"
 )
 
 // structs
@@ -31,27 +33,74 @@ type repomdXMLDataCheckSum struct {
 	Value string `xml:",chardata"`
 }
 
-type PrimaryDataXML struct {
-	Packages string       `xml:"packages,attr"`
-	Package  []RpmPackage `xml:"package"`
-}
-
 type RpmPackage struct {
-	Type    string `xml:"type,attr"`
-	Name    string `xml:"name"`
-	Arch    string `xml:"arch"`
-	Version struct {
-		Epoch string `xml:"epoch,attr"`
-		Ver   string `xml:"ver,attr"`
-		Rel   string `xml:"rel,attr"`
-	} `xml:"version"`
+	Type     string `xml:"type,attr"`
+	Name     string `xml:"name"`
+	Arch     string `xml:"arch"`
+	Version  EVR    `xml:"version"`
 	Checksum struct {
 		Type  string `xml:"type,attr"`
 		Value string `xml:",chardata"`
 	} `xml:"checksum"`
+	Size struct {
+		Package   int64 `xml:"package,attr"`
+		Installed int64 `xml:"installed,attr"`
+		Archive   int64 `xml:"archive,attr"`
+	} `xml:"size"`
 	Location struct {
 		Path string `xml:"href,attr"`
 	} `xml:"location"`
+	Format struct {
+		Provides []RpmEntry `xml:"provides>entry"`
+		Requires []RpmEntry `xml:"requires>entry"`
+		Files    []string   `xml:"file"`
+	} `xml:"format"`
+}
+
+// RpmEntry is a single provides or requires entry of a package. The flags are LT, GT, EQ, LE or GE for versioned entries
+// and empty when the entry is not versioned.
+type RpmEntry struct {
+	Name  string `xml:"name,attr"`
+	Flags string `xml:"flags,attr"`
+	Pre   string `xml:"pre,attr"`
+	EVR
+}
+
+// String returns the entry formatted as rpm does, for example
+// "glibc >= 2.17".
+func (e RpmEntry) String() string {
+	op, ok := entryFlagOperators[e.Flags]
+	if !ok {
+		return e.Name
+	}
+	return e.Name + " " + op + " " + e.EVR.String()
+}
+
+// Matches returns true when the EVR is in the range described by the
+// flags and EVR of the entry. An entry without flags matches any EVR.
+func (e RpmEntry) Matches(evr EVR) bool {
+	rc := CompareEVR(evr, e.EVR)
+	switch e.Flags {
+	case "EQ":
+		return rc == 0
+	case "LT":
+		return rc < 0
+	case "LE":
+		return rc <= 0
+	case "GT":
+		return rc > 0
+	case "GE":
+		return rc >= 0
+	}
+	return true
+}
+
+var entryFlagOperators = map[string]string{
+	"EQ": "=",
+	"LT": "<",
+	"LE": "<=",
+	"GT": ">",
+	"GE": ">=",
 }
 
 // constructor
@@ -79,22 +128,19 @@ func (rx *RepomdXML) Compare(other *RepomdXML) bool {
 	return false
 }
 
-func (rx *RepomdXML) Save(fname string) error {
-	return ioutil.WriteFile(fname, rx.data, 0644)
-}
-
-func NewPrimaryDataXML(r io.Reader) (*PrimaryDataXML, error) {
-	var err error
-	pm := &PrimaryDataXML{}
-	data, err := ioutil.ReadAll(r)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if err := xml.Unmarshal(data, pm); err != nil {
-		return nil, err
+// Timestamp returns the most recent timestamp of the metadata files
+// as Unix time, or 0 when none is set.
+func (rx *RepomdXML) Timestamp() int64 {
+	var ts int64
+	for _, v := range rx.Data {
+		t, err := strconv.ParseFloat(strings.TrimSpace(v.Timestamp), 64)
+		if err == nil && int64(t) > ts {
+			ts = int64(t)
+		}
 	}
+	return ts
+}
 
-	return pm, nil
+func (rx *RepomdXML) Save(fname string) error {
+	return ioutil.WriteFile(fname, rx.data, 0644)
 }
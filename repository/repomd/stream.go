@@ -0,0 +1,145 @@
+package repomd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// A PrimaryReader reads the packages of a primary.xml file one at a time,
+// so the memory use doesn't grow with the number of packages. Packages is
+// the package count announced by the document, 0 when not set.
+type PrimaryReader struct {
+	Packages int
+	er       *elementReader
+}
+
+// An OtherReader reads the changelogs of the packages of an other.xml
+// file one package at a time. Packages is the package count announced by
+// the document, 0 when not set.
+type OtherReader struct {
+	Packages int
+	er       *elementReader
+}
+
+// NewPrimaryReader returns a PrimaryReader reading from a primary.xml
+// document. It fails when the document doesn't start with a metadata
+// element.
+func NewPrimaryReader(r io.Reader) (*PrimaryReader, error) {
+	er, root, err := newElementReader(r, "metadata", "package")
+	if err != nil {
+		return nil, err
+	}
+	return &PrimaryReader{Packages: packagesAttr(root), er: er}, nil
+}
+
+// Next returns the next package. It returns io.EOF when there are no
+// more packages.
+func (pr *PrimaryReader) Next() (*RpmPackage, error) {
+	p := &RpmPackage{}
+	if err := pr.er.next(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewOtherReader returns an OtherReader reading from an other.xml
+// document. It fails when the document doesn't start with an otherdata
+// element.
+func NewOtherReader(r io.Reader) (*OtherReader, error) {
+	er, root, err := newElementReader(r, "otherdata", "package")
+	if err != nil {
+		return nil, err
+	}
+	return &OtherReader{Packages: packagesAttr(root), er: er}, nil
+}
+
+// Next returns the changelogs of the next package. It returns io.EOF when
+// there are no more packages.
+func (o *OtherReader) Next() (*OtherPackage, error) {
+	p := &OtherPackage{}
+	if err := o.er.next(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// elementReader decodes the child elements with a given name of the root
+// element of an XML document one at a time. Other child elements are
+// skipped.
+type elementReader struct {
+	d    *xml.Decoder
+	name string
+	done bool
+}
+
+// newElementReader returns an elementReader positioned after the start
+// of the root element, which is returned for its attributes.
+func newElementReader(r io.Reader, root string, name string) (*elementReader, xml.StartElement, error) {
+	d := xml.NewDecoder(r)
+	for {
+		t, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("no %s element found", root)
+			}
+			return nil, xml.StartElement{}, err
+		}
+
+		if se, ok := t.(xml.StartElement); ok {
+			if se.Name.Local != root {
+				return nil, xml.StartElement{}, fmt.Errorf("expected %s element, found %s", root, se.Name.Local)
+			}
+			return &elementReader{d: d, name: name}, se, nil
+		}
+	}
+}
+
+// next decodes the next element into v. It returns io.EOF at the end of
+// the root element.
+func (er *elementReader) next(v interface{}) error {
+	if er.done {
+		return io.EOF
+	}
+
+	for {
+		t, err := er.d.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+
+		switch se := t.(type) {
+		case xml.StartElement:
+			if se.Name.Local != er.name {
+				if err := er.d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			return er.d.DecodeElement(v, &se)
+		case xml.EndElement:
+			er.done = true
+			return io.EOF
+		}
+	}
+}
+
+// packagesAttr returns the value of the packages attribute of an element,
+// 0 when not set, invalid or negative. The count comes from the document,
+// so it's only informative and shouldn't be relied upon.
+func packagesAttr(se xml.StartElement) int {
+	for _, a := range se.Attr {
+		if a.Name.Local == "packages" {
+			n, err := strconv.Atoi(a.Value)
+			if err != nil || n < 0 {
+				return 0
+			}
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package repomd_test
+
+import (
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/catay/rrst/repository/repomd"
+)
+
+var _ = Describe("Stream", func() {
+
+	const primaryXML = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://linux.duke.edu/metadata/common" xmlns:rpm="http://linux.duke.edu/metadata/rpm" packages="2">
+<package type="rpm">
+  <name>foo</name>
+  <arch>x86_64</arch>
+  <version epoch="0" ver="1.0" rel="2"/>
+  <size package="1024" installed="2048" archive="2304"/>
+  <location href="Packages/foo-1.0-2.x86_64.rpm"/>
+  <format>
+    <rpm:requires>
+      <rpm:entry name="bar" flags="GE" epoch="0" ver="2.0"/>
+    </rpm:requires>
+  </format>
+</package>
+<package type="rpm">
+  <name>bar</name>
+  <arch>noarch</arch>
+  <version epoch="1" ver="2.0" rel="1"/>
+  <location href="Packages/bar-2.0-1.noarch.rpm"/>
+</package>
+</metadata>`
+
+	Describe("Given a function NewPrimaryReader(r io.Reader)", func() {
+		It("should read the packages one at a time", func() {
+			pr, err := NewPrimaryReader(strings.NewReader(primaryXML))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pr.Packages).To(Equal(2))
+
+			p, err := pr.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.Name).To(Equal("foo"))
+			Expect(p.Size.Package).To(Equal(int64(1024)))
+			Expect(p.Format.Requires).To(HaveLen(1))
+
+			p, err = pr.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.Version).To(Equal(EVR{"1", "2.0", "1"}))
+
+			_, err = pr.Next()
+			Expect(err).To(Equal(io.EOF))
+		})
+
+		It("should ignore an invalid or negative package count", func() {
+			for _, count := range []string{"-1", "many"} {
+				pr, err := NewPrimaryReader(strings.NewReader(strings.Replace(primaryXML, `packages="2"`, `packages="`+count+`"`, 1)))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pr.Packages).To(Equal(0))
+			}
+		})
+
+		It("should read all packages whatever the package count", func() {
+			for _, count := range []string{"-1", "0", "9223372036854775807"} {
+				pr, err := NewPrimaryReader(strings.NewReader(strings.Replace(primaryXML, `packages="2"`, `packages="`+count+`"`, 1)))
+				Expect(err).NotTo(HaveOccurred())
+
+				var n int
+				for {
+					_, err := pr.Next()
+					if err == io.EOF {
+						break
+					}
+					Expect(err).NotTo(HaveOccurred())
+					n++
+				}
+				Expect(n).To(Equal(2))
+			}
+		})
+
+		It("should fail on another document", func() {
+			_, err := NewPrimaryReader(strings.NewReader(`<otherdata packages="0"></otherdata>`))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should fail on a truncated document", func() {
+			pr, err := NewPrimaryReader(strings.NewReader(primaryXML[:strings.Index(primaryXML, "<name>bar")]))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = pr.Next()
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = pr.Next()
+			Expect(err).To(HaveOccurred())
+			Expect(err).NotTo(Equal(io.EOF))
+		})
+	})
+})
@@ -2,32 +2,45 @@ package repository
 
 import (
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"github.com/catay/rrst/api/suse"
 	"github.com/catay/rrst/config"
 	"github.com/catay/rrst/repository/repomd"
 	"github.com/catay/rrst/util/file"
 	h "github.com/catay/rrst/util/http"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
-	tmpSuffix   = ".filepart"
-	repoXMLfile = "/repodata/repomd.xml"
+	tmpSuffix        = ".filepart"
+	repoXMLfile      = "/repodata/repomd.xml"
+	revisionInfoFile = "/revision.yaml"
+	tagSeparator     = "%2F"
 )
 
+// ErrDeleteNotConfirmed is returned by Delete when force is false.
+var ErrDeleteNotConfirmed = errors.New("deletion not confirmed")
+
 // Repository data model.
 type Repository struct {
 	*config.RepositoryConfig
 	Revisions []*Revision
 	Tags      []*Tag
+
+	// problems found in the state on disk while initializing, which are
+	// skipped and reported by Fsck.
+	problems []FsckProblem
 }
 
 // Create a new repository
@@ -42,6 +55,10 @@ func NewRepository(repoConfig *config.RepositoryConfig) (*Repository, error) {
 
 	r.initState()
 
+	if len(r.problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%v: warning: %v problem(s) found in the content path, run rrst fsck\n", r.Name, len(r.problems))
+	}
+
 	return r, nil
 }
 
@@ -59,6 +76,11 @@ func (r *Repository) Update(rev int64) (bool, error) {
 	// assume there is a linked upstream repo
 	if r.RemoteURI == "" {
 		_, err = r.updateFromLocal(rev)
+	} else if r.RType == config.RrstRepositoryType {
+		if rev != 0 {
+			return false, fmt.Errorf("Updating a revision is not supported for %v repositories.", r.RType)
+		}
+		err = r.updateFromUpstreamTags()
 	} else {
 		_, err = r.updateFromRemote(rev)
 	}
@@ -67,16 +89,21 @@ func (r *Repository) Update(rev int64) (bool, error) {
 		return false, err
 	}
 
-	return r.tagLatestRevision(config.DefaultLatestRevisionTag)
+	return r.updateTags()
 }
 
 // The Tag method creates a tag symlink to the specified revision.
-// FIXME: add tag delete functionality.
+// Every tag change is recorded in the tag journal.
 func (r *Repository) Tag(tagname string, revid int64, force bool) (bool, error) {
+	return r.tag(tagname, revid, "")
+}
+
+// tag creates or moves a tag and records the change with a comment in
+// the tag journal.
+func (r *Repository) tag(tagname string, revid int64, comment string) (bool, error) {
 	// Check if the tag name is valid.
-	// A tag name can only contain lowercase and uppercase letters, digits and underscores.
 	if !r.isValidTagName(tagname) {
-		return false, fmt.Errorf("Tag %v not valid, only letters, digits and underscores allowed.", tagname)
+		return false, invalidTagError(tagname)
 	}
 
 	// Check if there is a matching revision with the give revid.
@@ -86,10 +113,13 @@ func (r *Repository) Tag(tagname string, revid int64, force bool) (bool, error)
 		return false, fmt.Errorf("Revision %v not found.", revid)
 	}
 
-	tagpath := r.ContentTagsPath + "/" + tagname
+	tagpath := r.TagPath(tagname)
 	revpath := r.getRevisionDir(rev)
 
 	// check if tag already exists, if not, create the tag symlink.
+	var previous *Revision
+	action := TagCreated
+
 	tag := r.tagByName(tagname)
 	if tag == nil {
 		r.addTag(NewTag(tagname, rev))
@@ -100,6 +130,8 @@ func (r *Repository) Tag(tagname string, revid int64, force bool) (bool, error)
 			if err := os.Remove(tagpath); err != nil {
 				return false, err
 			}
+			previous = tag.Revision
+			action = TagMoved
 			tag.SetRevision(rev)
 		}
 	}
@@ -108,37 +140,106 @@ func (r *Repository) Tag(tagname string, revid int64, force bool) (bool, error)
 		return false, err
 	}
 
-	return true, nil
+	return true, r.recordTagChange(tagname, action, previous, rev, comment)
+}
+
+// The DeleteTag method deletes a tag. The reserved latest tag can't be
+// deleted as it is managed by the update command.
+func (r *Repository) DeleteTag(tagname string) (bool, error) {
+	return r.deleteTag(tagname, "")
+}
+
+// deleteTag deletes a tag and records the change with a comment in the
+// tag journal.
+func (r *Repository) deleteTag(tagname string, comment string) (bool, error) {
+	if tagname == config.DefaultLatestRevisionTag {
+		return false, fmt.Errorf("Tag %v is reserved and can't be deleted.", tagname)
+	}
+
+	if r.IsProtectedTag(tagname) {
+		return false, fmt.Errorf("Tag %v is protected and can't be deleted.", tagname)
+	}
+
+	tag := r.tagByName(tagname)
+	if tag == nil {
+		return false, fmt.Errorf("Tag %v not found.", tagname)
+	}
+
+	if err := os.Remove(r.TagPath(tagname)); err != nil {
+		return false, err
+	}
+
+	r.removeTag(tag)
+	return true, r.recordTagChange(tagname, TagDeleted, tag.Revision, nil, comment)
+}
+
+// The RenameTag method renames a tag, the tag keeps pointing to the same
+// revision. The reserved latest tag can't be renamed and the new tag name
+// can't be in use already.
+func (r *Repository) RenameTag(oldname string, newname string) (bool, error) {
+	if oldname == config.DefaultLatestRevisionTag || newname == config.DefaultLatestRevisionTag {
+		return false, fmt.Errorf("Tag %v is reserved and can't be renamed.", config.DefaultLatestRevisionTag)
+	}
+
+	if r.IsProtectedTag(oldname) {
+		return false, fmt.Errorf("Tag %v is protected and can't be renamed.", oldname)
+	}
+
+	if !r.isValidTagName(newname) {
+		return false, invalidTagError(newname)
+	}
+
+	tag := r.tagByName(oldname)
+	if tag == nil {
+		return false, fmt.Errorf("Tag %v not found.", oldname)
+	}
+
+	if r.isTag(newname) {
+		return false, fmt.Errorf("Tag %v already exists.", newname)
+	}
+
+	// Create the new tag symlink before removing the old one, so the
+	// revision never ends up without a tag.
+	if err := os.Symlink(r.getRevisionDir(tag.Revision), r.TagPath(newname)); err != nil {
+		return false, err
+	}
+
+	if err := os.Remove(r.TagPath(oldname)); err != nil {
+		return false, err
+	}
+
+	tag.Name = newname
+
+	if err := r.recordTagChange(oldname, TagDeleted, tag.Revision, nil, "renamed to "+newname); err != nil {
+		return false, err
+	}
+
+	return true, r.recordTagChange(newname, TagCreated, nil, tag.Revision, "renamed from "+oldname)
 }
 
 // The Delete method deletes the content of a whole repository or from a
-// specific revision.
+// specific revision. Locked revisions and revisions holding a protected
+// tag are never deleted.
+// As deleting revisions can't be undone, the caller has to confirm the
+// deletion of the revisions returned by RevisionsToDelete first and set
+// force to true.
 func (r *Repository) Delete(revid int64, force bool) (bool, error) {
-	var revisions []*Revision
+	revisions, err := r.RevisionsToDelete(revid)
+	if err != nil {
+		return false, err
+	}
 
-	if !r.HasRevisions() {
-		fmt.Println("No repository revisions to delete.")
+	if len(revisions) == 0 {
 		return false, nil
 	}
 
-	// If no revision provided, delete all revisions, else only
-	// delete the provided revision when existing.
-	if revid == 0 {
-		revisions = r.Revisions
-	} else {
-		// Check if there is a matching revision with the give revid.
-		// If not, bail out.
-		rev := r.revisionById(revid)
-		if rev == nil {
-			return false, fmt.Errorf("Revision %v not found.", revid)
-		}
-
-		revisions = append(revisions, rev)
+	if !force {
+		return false, ErrDeleteNotConfirmed
 	}
 
 	for _, rev := range revisions {
 		if err := r.deleteRevisionDir(rev); err != nil {
-			return false, fmt.Errorf("Deleting revision %v failed: %v.", revid, err)
+			return false, fmt.Errorf("Deleting revision %v failed: %v.", rev.Id, err)
 		} else {
 			fmt.Printf("Deleting revision %v\n", rev.Id)
 		}
@@ -149,90 +250,61 @@ func (r *Repository) Delete(revid int64, force bool) (bool, error) {
 	return true, nil
 }
 
-// The PackageVersions method returns a hash with the package.arch name
-// as key and an array with the latest package version per tag or
-// revision.
-func (r *Repository) PackageVersions(tagsOrRevs ...string) (map[string][]string, error) {
-	// check if tags exist, if not bail out.
-	for _, t := range tagsOrRevs {
-		if !r.isTagOrRevId(t) {
-			return nil, fmt.Errorf("tag or revision %s not found", t)
-		}
-	}
-
-	packageMap := make(map[string][]string)
-
-	for i, t := range tagsOrRevs {
-
-		var rev *Revision
-
-		if r.isTag(t) {
-			rev = r.tagByName(t).Revision
-
-		} else {
-			// FIXME: no error checking for Atoi() can end badly.
-			id, _ := strconv.Atoi(t)
-			rev = r.revisionById(int64(id))
-		}
+// The RevisionsToDelete method returns the revisions the Delete method
+// would delete, all revisions when revid is 0. An error is returned when
+// one of the revisions is locked or holds a protected tag.
+func (r *Repository) RevisionsToDelete(revid int64) ([]*Revision, error) {
+	var revisions []*Revision
 
-		packages, err := r.getMetadataPackageList(rev)
-		if err != nil {
-			return nil, err
+	// If no revision provided, delete all revisions, else only
+	// delete the provided revision when existing.
+	if revid == 0 {
+		revisions = r.Revisions
+	} else {
+		// Check if there is a matching revision with the give revid.
+		// If not, bail out.
+		rev := r.revisionById(revid)
+		if rev == nil {
+			return nil, fmt.Errorf("Revision %v not found.", revid)
 		}
 
-		for _, p := range packages {
-			verRel := p.Version.Ver + "-" + p.Version.Rel
-			packageName := p.Name + "." + p.Arch
-			if _, ok := packageMap[packageName]; !ok {
-				packageMap[packageName] = make([]string, len(tagsOrRevs))
-			}
+		revisions = append(revisions, rev)
+	}
 
-			packageMap[packageName][i] = verRel
+	for _, rev := range revisions {
+		if rev.IsLocked() {
+			return nil, fmt.Errorf("Revision %v is locked and can't be deleted.", rev.Id)
 		}
-	}
 
-	// set the version string to - when the package is not present in a
-	// tagged revision
-	for _, v := range packageMap {
-		for i := range v {
-			if v[i] == "" {
-				v[i] = "-"
+		for _, tag := range rev.Tags {
+			if r.IsProtectedTag(tag.Name) {
+				return nil, fmt.Errorf("Revision %v holds protected tag %v and can't be deleted.", rev.Id, tag.Name)
 			}
 		}
 	}
 
-	return packageMap, nil
+	return revisions, nil
 }
 
-// The Diff method shows the package differences between tags.
-func (r *Repository) Diff(tags ...string) (map[string][]string, error) {
-	// check if tags exist, if not bail out.
-	for _, t := range tags {
-		if !r.isTag(t) {
-			return nil, fmt.Errorf("tag %s not found", t)
+// The IsProtectedTag method returns true if the tag is configured as
+// protected. Protected tags can be moved, but not deleted or renamed.
+func (r *Repository) IsProtectedTag(tagname string) bool {
+	for _, t := range r.ProtectedTags {
+		if t == tagname {
+			return true
 		}
 	}
+	return false
+}
 
-	packageDiff := make(map[string][]string)
-
-	for i, t := range tags {
-		packages, err := r.getMetadataPackageList(r.tagByName(t).Revision)
-		if err != nil {
-			return nil, err
-		}
-
-		for _, p := range packages {
-			verRel := p.Version.Ver + "-" + p.Version.Rel
-			packageName := p.Name + "." + p.Arch
-			if _, ok := packageDiff[packageName]; !ok {
-				packageDiff[packageName] = make([]string, len(tags))
-			}
-
-			packageDiff[packageName][i] = verRel
-		}
+// The Packages method returns the packages of a tag or revision.
+func (r *Repository) Packages(tagOrRev string) ([]repomd.RpmPackage, error) {
+	rev := r.revisionByTagOrRevId(tagOrRev)
+	if rev == nil {
+		return nil, fmt.Errorf("tag or revision %s not found", tagOrRev)
 	}
 
-	return packageDiff, nil
+	return r.getMetadataPackageList(rev)
 }
 
 // RefreshState refreshes the underlying tag and revision state.
@@ -260,8 +332,8 @@ func (r *Repository) initDirectories() error {
 }
 
 // The initState method updates the data structures with the state on disk.
-// FIXME: probably best to panic in case those return an error. That would
-// mean the state under the content path is corrupted.
+// Directories and tags which can't be loaded are skipped and kept as
+// problems for Fsck.
 func (r *Repository) initState() error {
 	r.resetState()
 	r.initRevisionState()
@@ -269,16 +341,18 @@ func (r *Repository) initState() error {
 	return nil
 }
 
-// resetState sets the Tags and Revisions slices to nil.
+// resetState sets the Tags, Revisions and problems slices to nil.
 // This ensures the underlying memory is properly released released.
 func (r *Repository) resetState() {
 	r.Tags = nil
 	r.Revisions = nil
+	r.problems = nil
 }
 
 // initRevisionState fetches all revision directories of the metadata dir.
-// FIXME: add extra check to make sure there is a repomd.xml file in the tag dir.
-// FIXME: also make sure only tags get added with correct pattern.
+// Directories which aren't revisions and revisions which can't be loaded
+// are skipped. Nothing is written, revision directories of older releases
+// are only migrated when a revision gets created.
 func (r *Repository) initRevisionState() error {
 	revIds, err := r.getRevIdsFromPath()
 	if err != nil {
@@ -286,12 +360,256 @@ func (r *Repository) initRevisionState() error {
 	}
 
 	for _, id := range revIds {
-		r.addRevision(NewRevisionFromId(id))
+		rev, err := r.loadRevision(id)
+		if err != nil {
+			r.problems = append(r.problems, FsckProblem{
+				Path:    r.getRevisionDir(&Revision{Id: id}),
+				Problem: FsckInvalidRevision,
+				Detail:  strings.Join(strings.Fields(err.Error()), " "),
+			})
+			continue
+		}
+		r.addRevision(rev)
+	}
+
+	dirs, err := r.getNonRevisionDirs()
+	if err != nil {
+		return err
 	}
 
+	for _, d := range dirs {
+		r.problems = append(r.problems, FsckProblem{
+			Path:    d,
+			Problem: FsckNotRevision,
+		})
+	}
+
+	sort.Slice(r.Revisions, func(i, j int) bool {
+		return r.Revisions[i].Id < r.Revisions[j].Id
+	})
+
 	return err
 }
 
+// loadRevision loads a revision with its manifest, annotations and lock.
+// Only a revision info file which can't be loaded fails the revision. A
+// manifest, annotations or lock file which can't be loaded is kept as
+// problem for Fsck and the revision is loaded without it. A revision with
+// an unreadable lock file stays locked, with an empty lock, so it can't
+// be changed before the lock file got repaired.
+func (r *Repository) loadRevision(id int64) (*Revision, error) {
+	var rev *Revision
+	if file.IsRegularFile(r.getRevisionInfoPath(id)) {
+		var err error
+		rev, err = NewRevisionFromFile(r.getRevisionInfoPath(id))
+		if err != nil {
+			return nil, err
+		}
+		if rev.Id != id {
+			return nil, fmt.Errorf("%s: revision id %v doesn't match the directory", r.getRevisionInfoPath(id), rev.Id)
+		}
+	} else {
+		// A revision directory of an older release keeps the Unix time
+		// of its creation as id until it's migrated.
+		rev = &Revision{Id: id, Created: time.Unix(id, 0), LegacyId: id}
+	}
+
+	files := []struct {
+		path string
+		load func(*Revision) error
+	}{
+		{r.getManifestPath(rev), r.loadManifest},
+		{r.getAnnotationsPath(rev), r.loadAnnotations},
+		{r.getLockPath(rev), r.loadLock},
+	}
+
+	for _, f := range files {
+		if err := f.load(rev); err != nil {
+			r.problems = append(r.problems, FsckProblem{
+				Path:    f.path,
+				Problem: FsckInvalidFile,
+				Detail:  strings.Join(strings.Fields(err.Error()), " "),
+			})
+		}
+	}
+
+	if rev.Lock == nil && file.IsRegularFile(r.getLockPath(rev)) {
+		rev.Lock = &Lock{}
+	}
+
+	return rev, nil
+}
+
+// migrateLegacyRevisions converts the revision directories of older
+// releases, named after the Unix time of their creation, to sequential
+// revision ids. The Unix time is kept as creation time and legacy id, and
+// the tags are relinked to the renamed revision directories. It returns
+// true when revisions got migrated, the state has to be reloaded then.
+func (r *Repository) migrateLegacyRevisions() (bool, error) {
+	revIds, err := r.getRevIdsFromPath()
+	if err != nil {
+		return false, err
+	}
+
+	var legacyIds []int64
+	for _, id := range revIds {
+		if !file.IsRegularFile(r.getRevisionInfoPath(id)) {
+			legacyIds = append(legacyIds, id)
+		}
+	}
+
+	if len(legacyIds) == 0 {
+		return false, nil
+	}
+
+	sort.Slice(legacyIds, func(i, j int) bool { return legacyIds[i] < legacyIds[j] })
+
+	migrated := make(map[string]*Revision)
+	for _, legacyId := range legacyIds {
+		rev := &Revision{
+			Id:       legacyId,
+			Created:  time.Unix(legacyId, 0),
+			LegacyId: legacyId,
+		}
+
+		if id, err := r.nextRevisionId(); err != nil {
+			return false, err
+		} else if id < legacyId {
+			rev.Id = id
+		}
+
+		if rev.Id != legacyId {
+			if err := os.Rename(r.getRevisionDir(&Revision{Id: legacyId}), r.getRevisionDir(rev)); err != nil {
+				return false, err
+			}
+		}
+
+		if err := rev.Save(r.getRevisionInfoPath(rev.Id)); err != nil {
+			return false, err
+		}
+
+		migrated[fmt.Sprintf("%v", legacyId)] = rev
+		fmt.Printf("Migrated revision %v of %v to revision %v\n", legacyId, r.Name, rev.Id)
+	}
+
+	// Relink the tags of the renamed revision directories.
+	files, err := ioutil.ReadDir(r.ContentTagsPath)
+	if err != nil {
+		return false, err
+	}
+
+	for _, v := range files {
+		if v.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		tagpath := r.ContentTagsPath + "/" + v.Name()
+		target, err := os.Readlink(tagpath)
+		if err != nil {
+			return false, err
+		}
+
+		rev, ok := migrated[filepath.Base(target)]
+		if !ok || rev.Id == rev.LegacyId {
+			continue
+		}
+
+		if err := os.Remove(tagpath); err != nil {
+			return false, err
+		}
+
+		if err := os.Symlink(r.getRevisionDir(rev), tagpath); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// nextRevisionId returns the lowest revision id above all the revision
+// ids on disk.
+func (r *Repository) nextRevisionId() (int64, error) {
+	var next int64 = 1
+
+	revIds, err := r.getRevIdsFromPath()
+	if err != nil {
+		return next, err
+	}
+
+	for _, id := range revIds {
+		if file.IsRegularFile(r.getRevisionInfoPath(id)) && id >= next {
+			next = id + 1
+		}
+	}
+
+	return next, nil
+}
+
+// createRevision allocates a new revision id and creates the revision
+// directory with the revision info in it.
+// The revision directory is prepared in the tmp dir and renamed into place,
+// which fails when the id got claimed in the meantime. So concurrent
+// updates sharing the same metadata path never end up with the same
+// revision.
+func (r *Repository) createRevision() (*Revision, error) {
+	return r.createRevisionWith(nil)
+}
+
+// createRevisionWith creates a new revision like createRevision, the fill
+// function can add content to the revision directory before it's renamed
+// into place.
+func (r *Repository) createRevisionWith(fill func(dir string) error) (*Revision, error) {
+	// Revisions of older releases are migrated first, so the new revision
+	// gets the id following theirs.
+	migrated, err := r.migrateLegacyRevisions()
+	if err != nil {
+		return nil, err
+	}
+	if migrated {
+		r.initState()
+	}
+
+	id, err := r.nextRevisionId()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := ioutil.TempDir(r.ContentTmpPath, "revision")
+	if err != nil {
+		return nil, fmt.Errorf("revision creation failed: %s", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.Mkdir(tmpDir+"/repodata", 0700); err != nil {
+		return nil, fmt.Errorf("revision creation failed: %s", err)
+	}
+
+	if fill != nil {
+		if err := fill(tmpDir); err != nil {
+			return nil, fmt.Errorf("revision creation failed: %s", err)
+		}
+	}
+
+	rev := NewRevision(id)
+	for {
+		if err := rev.Save(tmpDir + revisionInfoFile); err != nil {
+			return nil, fmt.Errorf("revision creation failed: %s", err)
+		}
+
+		err := os.Rename(tmpDir, r.getRevisionDir(rev))
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("revision creation failed: %s", err)
+		}
+		rev.Id++
+	}
+
+	r.addRevision(rev)
+	return rev, nil
+}
+
 // addRevision adds Revision to the repository revision list.
 // Returns true when added, false when not added.
 func (r *Repository) addRevision(rev *Revision) bool {
@@ -317,7 +635,8 @@ func (r *Repository) appendRevisionIfMissing(rev *Revision) bool {
 }
 
 // getRevIdsFromPath reads all revision id's from the filesystem and
-// returns it as an array.
+// returns it as an array. Directories not named after a revision id are
+// skipped.
 func (r *Repository) getRevIdsFromPath() ([]int64, error) {
 	var revIds []int64
 	files, err := ioutil.ReadDir(r.ContentMDPath)
@@ -327,17 +646,38 @@ func (r *Repository) getRevIdsFromPath() ([]int64, error) {
 
 	for _, v := range files {
 		if v.IsDir() {
-			revid, err := strconv.Atoi(v.Name())
-			if err != nil {
-				return revIds, err
+			revid, err := strconv.ParseInt(v.Name(), 10, 64)
+			if err != nil || revid <= 0 {
+				continue
+			}
+			revIds = append(revIds, revid)
+		}
+	}
+	return revIds, nil
+}
+
+// getNonRevisionDirs returns the directories of the metadata dir which
+// are not named after a revision id.
+func (r *Repository) getNonRevisionDirs() ([]string, error) {
+	var dirs []string
+	files, err := ioutil.ReadDir(r.ContentMDPath)
+	if err != nil {
+		return dirs, err
+	}
+
+	for _, v := range files {
+		if v.IsDir() {
+			revid, err := strconv.ParseInt(v.Name(), 10, 64)
+			if err != nil || revid <= 0 {
+				dirs = append(dirs, r.ContentMDPath+"/"+v.Name())
 			}
-			revIds = append(revIds, int64(revid))
 		}
 	}
-	return revIds, err
+	return dirs, nil
 }
 
 // revisionById returns a Revision with the matchin revision id.
+// Migrated revisions can also be found by their legacy id.
 // The returned Revision will be nil when not found.
 func (r *Repository) revisionById(id int64) *Revision {
 	for i, v := range r.Revisions {
@@ -345,6 +685,12 @@ func (r *Repository) revisionById(id int64) *Revision {
 			return r.Revisions[i]
 		}
 	}
+
+	for i, v := range r.Revisions {
+		if v.LegacyId != 0 && v.LegacyId == id {
+			return r.Revisions[i]
+		}
+	}
 	return nil
 }
 
@@ -389,6 +735,12 @@ func (r *Repository) getRevisionDir(rev *Revision) string {
 	return revisionDir
 }
 
+// The getRevisionInfoPath method returns the path of the revision info
+// file of a revision id.
+func (r *Repository) getRevisionInfoPath(id int64) string {
+	return r.ContentMDPath + "/" + fmt.Sprintf("%v", id) + revisionInfoFile
+}
+
 // The getLatestRevision returns the most recent revision and a bool set
 // to true if found. If not found it returns an empty revision and a bool
 // set to false.
@@ -430,17 +782,6 @@ func (r *Repository) tagLatestRevision(tagname string) (bool, error) {
 	return r.Tag(tagname, revision.Id, true)
 }
 
-// The createRevisionDir method creates the revision directory under
-// the metadata structure.
-func (r *Repository) createRevisionDir(rev *Revision) error {
-	revisionDir := r.getRevisionDir(rev) + "/repodata"
-
-	if err := os.MkdirAll(revisionDir, 0700); err != nil {
-		return err
-	}
-	return nil
-}
-
 // The deleteRevisionDir method deletes the revision directory and tag
 // symbolic links under the metadata structure.
 func (r *Repository) deleteRevisionDir(rev *Revision) error {
@@ -448,7 +789,10 @@ func (r *Repository) deleteRevisionDir(rev *Revision) error {
 
 	// Remove the tag symbolic links linked to this revision first.
 	for _, tag := range rev.Tags {
-		if err := os.Remove(r.ContentTagsPath + "/" + tag.Name); err != nil {
+		if err := os.Remove(r.TagPath(tag.Name)); err != nil {
+			return err
+		}
+		if err := r.recordTagChange(tag.Name, TagDeleted, rev, nil, "revision deleted"); err != nil {
 			return err
 		}
 	}
@@ -470,22 +814,70 @@ func (r *Repository) initTagState() error {
 	for _, v := range files {
 		if v.Mode()&os.ModeSymlink != 0 {
 			tagpath := r.ContentTagsPath + "/" + v.Name()
-			revpath, err := filepath.EvalSymlinks(tagpath)
-			if err != nil {
-				return err
+
+			// Skip links which don't decode to a valid tag name, they
+			// were not created by rrst.
+			tagname := tagNameFromFile(v.Name())
+			if !r.isValidTagName(tagname) {
+				r.problems = append(r.problems, FsckProblem{
+					Path:    tagpath,
+					Problem: FsckForeignTag,
+					Detail:  "invalid tag name",
+				})
+				continue
 			}
 
-			i, err := strconv.Atoi(filepath.Base(revpath))
-			if err != nil {
-				return err
+			rev, problem := r.revisionByTagPath(tagpath)
+			if problem != nil {
+				r.problems = append(r.problems, *problem)
+				continue
 			}
-			rev := r.revisionById(int64(i))
-			r.addTag(NewTag(v.Name(), rev))
+			r.addTag(NewTag(tagname, rev))
 		}
 	}
 	return err
 }
 
+// revisionByTagPath returns the revision a tag symbolic link points to.
+// A problem is returned instead when the link is dangling or points
+// outside the metadata dir.
+func (r *Repository) revisionByTagPath(tagpath string) (*Revision, *FsckProblem) {
+	target, err := os.Readlink(tagpath)
+	if err != nil {
+		return nil, &FsckProblem{Path: tagpath, Problem: FsckForeignTag, Detail: err.Error()}
+	}
+
+	// Only a tag of which the target is gone is dangling.
+	revpath, err := filepath.EvalSymlinks(tagpath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &FsckProblem{Path: tagpath, Problem: FsckDanglingTag, Detail: "points to " + target}
+		}
+		return nil, &FsckProblem{Path: tagpath, Problem: FsckUnreadableRevision, Detail: err.Error()}
+	}
+
+	mdpath, err := filepath.EvalSymlinks(r.ContentMDPath)
+	if err != nil {
+		return nil, &FsckProblem{Path: tagpath, Problem: FsckForeignTag, Detail: err.Error()}
+	}
+
+	if filepath.Dir(revpath) != mdpath {
+		return nil, &FsckProblem{Path: tagpath, Problem: FsckForeignTag, Detail: "points to " + target}
+	}
+
+	id, err := strconv.ParseInt(filepath.Base(revpath), 10, 64)
+	if err != nil {
+		return nil, &FsckProblem{Path: tagpath, Problem: FsckForeignTag, Detail: "points to " + target}
+	}
+
+	// The target exists but the revision couldn't be loaded.
+	rev := r.revisionById(id)
+	if rev == nil || rev.Id != id {
+		return nil, &FsckProblem{Path: tagpath, Problem: FsckUnreadableRevision, Detail: "points to " + target}
+	}
+	return rev, nil
+}
+
 // addTag adds a Tag to the repository Tag list.
 // A boolean will return true when added, false when not.
 func (r *Repository) addTag(tag *Tag) bool {
@@ -508,6 +900,21 @@ func (r *Repository) appendTagIfMissing(tag *Tag) bool {
 	return missing
 }
 
+// removeTag removes a Tag from the repository Tag list and unlinks it
+// from its revision.
+func (r *Repository) removeTag(tag *Tag) {
+	for i, t := range r.Tags {
+		if t == tag {
+			r.Tags = append(r.Tags[:i], r.Tags[i+1:]...)
+			break
+		}
+	}
+
+	if tag.Revision != nil {
+		tag.Revision.DeleteTag(tag)
+	}
+}
+
 // tagByName returns a Tag with a matching tag name.
 // The returned Tag will be nil if not found.
 func (r *Repository) tagByName(tagname string) *Tag {
@@ -552,12 +959,65 @@ func (r *Repository) isTagOrRevId(value string) bool {
 
 }
 
+// revisionByTagOrRevId returns the Revision matching a tag name or a
+// revision id. The returned Revision will be nil when not found.
+func (r *Repository) revisionByTagOrRevId(value string) *Revision {
+	if tag := r.tagByName(value); tag != nil {
+		return tag.Revision
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	return r.revisionById(id)
+}
+
+// The RevisionByTagOrRevId method returns the Revision matching a tag
+// name or a revision id. The boolean is false when not found.
+func (r *Repository) RevisionByTagOrRevId(value string) (*Revision, bool) {
+	rev := r.revisionByTagOrRevId(value)
+	return rev, rev != nil
+}
+
 // isValidTagName checks if the tag name matches the pattern and
 // returns true or false. A tag name can only contain lowercase and
-// uppercase letters, digits and underscores.
+// uppercase letters, digits, underscores, dots and dashes. Slashes
+// separate the parts of hierarchical tag names like prod/eu, which can't
+// start with a dot, so no part can refer to a parent directory.
+// A repodata part is refused as it would shadow the metadata URL of the
+// parent tag.
 func (r *Repository) isValidTagName(tagname string) bool {
 	matched, _ := regexp.MatchString(config.ValidTagsRegex, tagname)
-	return matched
+	if !matched {
+		return false
+	}
+
+	for _, part := range strings.Split(tagname, "/") {
+		if part == "repodata" {
+			return false
+		}
+	}
+	return true
+}
+
+// invalidTagError returns the error for an invalid tag name.
+func invalidTagError(tagname string) error {
+	return fmt.Errorf("Tag %v not valid, only letters, digits, underscores, dots and dashes allowed, "+
+		"with slashes separating hierarchical tag names.", tagname)
+}
+
+// The TagPath method returns the path of the tag symbolic link. The
+// slashes of hierarchical tag names are encoded, so all tags are stored
+// as flat symbolic links in the tags directory.
+func (r *Repository) TagPath(tagname string) string {
+	return r.ContentTagsPath + "/" + strings.Replace(tagname, "/", tagSeparator, -1)
+}
+
+// tagNameFromFile returns the tag name of a tag symbolic link.
+func tagNameFromFile(name string) string {
+	return strings.Replace(name, tagSeparator, "/", -1)
 }
 
 // The getMetadata method downloads the repomd metadata when required and
@@ -565,7 +1025,7 @@ func (r *Repository) isValidTagName(tagname string) bool {
 func (r *Repository) getMetadata() (*Revision, error) {
 	rev, ok := r.getLatestRevision()
 
-	current, err := r.getUpstreamMetadata()
+	current, err := r.getUpstreamMetadata(r.RemoteURI)
 	if err != nil {
 		return rev, err
 	}
@@ -581,30 +1041,46 @@ func (r *Repository) getMetadata() (*Revision, error) {
 		}
 	}
 
-	rev = NewRevision()
+	// The metadata is downloaded before the revision directory is renamed
+	// into place, so a failed download never leaves a revision with
+	// incomplete metadata behind.
+	rev, err = r.createRevisionWith(func(dir string) error {
+		return r.downloadMetadata(dir, r.RemoteURI, current)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	if err := r.createRevisionDir(rev); err != nil {
-		return rev, fmt.Errorf("revision creation failed: %s", err)
+	// Reloading the state resets the problems found before, so they
+	// aren't reported twice.
+	r.RefreshState()
+	if reloaded := r.revisionById(rev.Id); reloaded != nil {
+		rev = reloaded
 	}
+	return rev, nil
+}
 
-	if err := current.Save(r.getRevisionDir(rev) + repoXMLfile); err != nil {
-		return rev, err
+// The downloadMetadata method saves the repomd.xml and downloads the
+// metadata files it references from the remote URL into a revision
+// directory.
+func (r *Repository) downloadMetadata(dir string, uri string, current *repomd.RepomdXML) error {
+	if err := current.Save(dir + repoXMLfile); err != nil {
+		return err
 	}
 
 	for _, v := range current.Data {
-		if err := h.HttpGetFile(r.providerURLconversion(r.RemoteURI+"/"+v.Location.Path), r.getRevisionDir(rev)+"/"+v.Location.Path); err != nil {
-			return rev, err
+		if err := h.HttpGetFile(r.providerURLconversion(uri+"/"+v.Location.Path), dir+"/"+v.Location.Path); err != nil {
+			return err
 		}
 	}
 
-	r.initRevisionState()
-	return rev, err
+	return nil
 }
 
 // The getUpstreamMetadata method fetches a remote repomd.xml in memory
 // and returns a RepomdXML type.
-func (r *Repository) getUpstreamMetadata() (*repomd.RepomdXML, error) {
-	req, err := http.NewRequest("GET", r.providerURLconversion(r.RemoteURI+repoXMLfile), nil)
+func (r *Repository) getUpstreamMetadata(uri string) (*repomd.RepomdXML, error) {
+	req, err := http.NewRequest("GET", r.providerURLconversion(uri+repoXMLfile), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -634,6 +1110,8 @@ func (r *Repository) updateFromRemote(rev int64) (*Revision, error) {
 	var revision *Revision
 	var err error
 
+	start := time.Now()
+
 	// If revision not set, new metadata has to be fetched and will set the revision
 	// If revision set, metadata should already be there
 	if rev == 0 {
@@ -642,24 +1120,35 @@ func (r *Repository) updateFromRemote(rev int64) (*Revision, error) {
 			return nil, err
 		}
 	} else {
-		revision = NewRevisionFromId(rev)
-		if !r.isRevision(revision) {
+		revision = r.revisionById(rev)
+		if revision == nil {
 			return nil, fmt.Errorf("Not a valid or existing revision.")
 		}
 	}
 
-	_, err = r.getPackages(revision)
+	// The content of a locked revision is frozen. When the upstream
+	// repository didn't change, there's nothing to update.
+	if revision.IsLocked() {
+		if rev != 0 {
+			return nil, fmt.Errorf("Revision %v is locked and can't be updated.", rev)
+		}
+		return revision, nil
+	}
+
+	downloaded, err := r.getPackages(revision, r.RemoteURI)
 	if err != nil {
 		return nil, err
 	}
 
-	return revision, err
+	return revision, r.writeManifest(revision, r.RemoteURI, downloaded, time.Since(start))
 }
 
 // updateFromLocal will handle all required operations for repositories
 // without a remote URL set.
 func (r *Repository) updateFromLocal(rev int64) (*Revision, error) {
 	var refresh bool
+
+	start := time.Now()
 	localPackages, err := r.getLocalPackageList()
 
 	if err != nil {
@@ -672,12 +1161,7 @@ func (r *Repository) updateFromLocal(rev int64) (*Revision, error) {
 
 	revision, ok := r.getLatestRevision()
 	if ok {
-		mdPackages, err := r.getMetadataPackageList(revision)
-		if err != nil {
-			return nil, err
-		}
-
-		for _, v := range mdPackages {
+		err := r.walkMetadataPackages(revision, func(v *repomd.RpmPackage) error {
 			_, ok := localPackages[r.ContentFilesPath+"/"+v.Location.Path]
 			if ok {
 				localPackages[r.ContentFilesPath+"/"+v.Location.Path] = true
@@ -686,6 +1170,10 @@ func (r *Repository) updateFromLocal(rev int64) (*Revision, error) {
 				// on the local filesystem and a refreh is forced.
 				refresh = true
 			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
 		}
 
 		for _, v := range localPackages {
@@ -699,22 +1187,20 @@ func (r *Repository) updateFromLocal(rev int64) (*Revision, error) {
 	}
 
 	if refresh {
-		revision = NewRevision()
-		err = r.refreshLocalMetadata(revision)
+		revision, err = r.createRevision()
+		if err != nil {
+			return nil, err
+		}
+		err = r.createRepo(r.getRevisionDir(revision), r.ContentFilesPath)
+		if err == nil {
+			err = r.writeManifest(revision, "file://"+r.ContentFilesPath, 0, time.Since(start))
+		}
 	}
 
 	fmt.Printf("\033[2K\r%-40v\t[%5[2]v/%-5[2]v]\tDone\n", r.Name, len(localPackages))
 	return revision, err
 }
 
-// refreshLocalMetadata creates new metadata for a revision.
-func (r *Repository) refreshLocalMetadata(revision *Revision) error {
-	if err := r.createRevisionDir(revision); err != nil {
-		return fmt.Errorf("revision creation failed: %s", err)
-	}
-	return r.createRepo(r.getRevisionDir(revision), r.ContentFilesPath)
-}
-
 // createRepo executes the createrepo command to refresh the metadata.
 func (r *Repository) createRepo(outputDir string, contentDir string) error {
 	_, err := exec.Command(config.CreateRepoCmd(), config.CreateRepoOpts, outputDir, contentDir).Output()
@@ -740,34 +1226,106 @@ func (r *Repository) getLocalPackageList() (map[string]bool, error) {
 	return localPackages, err
 }
 
-// The getPackages method downloads the upstream packages.
-// If packages are downloaded true will be returned, if not false.
-func (r *Repository) getPackages(rev *Revision) (bool, error) {
-	packages, err := r.getMetadataPackageList(rev)
+// The getPackages method downloads the upstream packages. The packages are
+// read from the metadata one at a time, so large repositories don't need
+// to fit in memory. It returns the number of bytes downloaded.
+func (r *Repository) getPackages(rev *Revision, uri string) (int64, error) {
+	var downloaded int64
+
+	f, err := r.openMetadata(rev, "primary")
 	if err != nil {
-		return false, err
+		return downloaded, err
+	}
+	defer f.Close()
+
+	pr, err := repomd.NewPrimaryReader(f)
+	if err != nil {
+		return downloaded, err
 	}
 
-	total := len(packages)
+	total := pr.Packages
 
-	for i, v := range packages {
-		fmt.Printf("\033[2K\r%-40v\t[%5v/%-5v]\t%v", r.Name, i+1, total, v.Location.Path)
-		if !file.IsRegularFile(r.ContentFilesPath + "/" + v.Location.Path) {
-			if err := h.HttpGetFile(r.providerURLconversion(r.RemoteURI+"/"+v.Location.Path), r.ContentFilesPath+"/"+v.Location.Path); err != nil {
-				return false, err
+	var i int
+	for {
+		v, err := pr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return downloaded, err
+		}
+
+		i++
+		fmt.Printf("\033[2K\r%-40v\t[%5v/%-5v]\t%v", r.Name, i, total, v.Location.Path)
+		if !isSafeRelPath(v.Location.Path) {
+			return downloaded, fmt.Errorf("package location %s not allowed", v.Location.Path)
+		}
+
+		filename := r.ContentFilesPath + "/" + v.Location.Path
+		if !file.IsRegularFile(filename) {
+			if err := h.HttpGetFile(r.providerURLconversion(uri+"/"+v.Location.Path), filename); err != nil {
+				return downloaded, err
+			}
+			if err := r.checkLockedPackage(v.Location.Path, filename); err != nil {
+				os.Remove(filename)
+				return downloaded, fmt.Errorf("%s: %s", v.Location.Path, err)
+			}
+			if fi, err := os.Stat(filename); err == nil {
+				downloaded += fi.Size()
 			}
 		}
 	}
 
-	fmt.Printf("\033[2K\r%-40v\t[%5[2]v/%-5[2]v]\tDone\n", r.Name, total)
+	fmt.Printf("\033[2K\r%-40v\t[%5[2]v/%-5[2]v]\tDone\n", r.Name, i)
 
-	return true, err
+	return downloaded, nil
 }
 
 // getMetadataPackageList returns an array of RPM packages out of the
 // metadata for the given revision.
 func (r *Repository) getMetadataPackageList(rev *Revision) ([]repomd.RpmPackage, error) {
-	var primaryDataPath string
+	var packages []repomd.RpmPackage
+	err := r.walkMetadataPackages(rev, func(p *repomd.RpmPackage) error {
+		packages = append(packages, *p)
+		return nil
+	})
+	return packages, err
+}
+
+// walkMetadataPackages calls fn for each RPM package of the metadata of
+// the given revision, reading one package at a time. It stops at the
+// first error returned by fn.
+func (r *Repository) walkMetadataPackages(rev *Revision, fn func(p *repomd.RpmPackage) error) error {
+	f, err := r.openMetadata(rev, "primary")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pr, err := repomd.NewPrimaryReader(f)
+	if err != nil {
+		return err
+	}
+
+	for {
+		p, err := pr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+}
+
+// openMetadata opens a gzip compressed metadata file of a revision by its
+// type in repomd.xml, like primary or other.
+func (r *Repository) openMetadata(rev *Revision, dataType string) (io.ReadCloser, error) {
+	var dataPath string
 
 	rm, err := r.getLocalMetadata(rev)
 	if err != nil {
@@ -775,27 +1333,40 @@ func (r *Repository) getMetadataPackageList(rev *Revision) ([]repomd.RpmPackage,
 	}
 
 	for _, v := range rm.Data {
-		if v.Type == "primary" {
-			primaryDataPath = r.getRevisionDir(rev) + "/" + v.Location.Path
+		if v.Type == dataType {
+			dataPath = r.getRevisionDir(rev) + "/" + v.Location.Path
 		}
 	}
 
-	f, err := os.Open(primaryDataPath)
-	if err != nil {
-		return nil, err
+	if dataPath == "" {
+		return nil, fmt.Errorf("revision %v has no %s metadata", rev.Id, dataType)
 	}
 
-	uf, err := gzip.NewReader(f)
+	f, err := os.Open(dataPath)
 	if err != nil {
 		return nil, err
 	}
 
-	pm, err := repomd.NewPrimaryDataXML(uf)
+	uf, err := gzip.NewReader(f)
 	if err != nil {
+		f.Close()
 		return nil, err
 	}
 
-	return pm.Package, nil
+	return &metadataReader{uf, f}, nil
+}
+
+// metadataReader reads a gzip compressed metadata file and closes both
+// the gzip reader and the file.
+type metadataReader struct {
+	*gzip.Reader
+	f *os.File
+}
+
+// Close closes the gzip reader and the underlying file.
+func (m *metadataReader) Close() error {
+	m.Reader.Close()
+	return m.f.Close()
 }
 
 // providerURLconversion is a dirty hack to deal with the provider specifics.
@@ -2,33 +2,59 @@ package repository
 
 import (
 	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
 	"time"
 )
 
 // A Revision represents a generic revision, identified by an Id and a
 // list of linked tags.
+//
+// The Id is a sequence number unique within a repository. The creation
+// time is stored separately. Revisions created by older releases were
+// identified by the Unix time of their creation, that id is kept as
+// LegacyId after migration.
 type Revision struct {
-	Id   int64
-	Tags []*Tag
+	Id          int64        `yaml:"id"`
+	Created     time.Time    `yaml:"created"`
+	LegacyId    int64        `yaml:"legacy_id,omitempty"`
+	Tags        []*Tag       `yaml:"-"`
+	Manifest    *Manifest    `yaml:"-"`
+	Annotations *Annotations `yaml:"-"`
+	Lock        *Lock        `yaml:"-"`
 }
 
-// NewRevision returns a new Revision.
-// The identifier is populated with the current Unix time.
-// FIXME: consider switching to github.com/satori/go.uuid
-func NewRevision() *Revision {
+// NewRevision returns a new Revision with the identifier set to the
+// value passed as argument and the creation time set to now.
+func NewRevision(id int64) *Revision {
 	re := &Revision{
-		Id: time.Now().Unix(),
+		Id:      id,
+		Created: time.Now(),
 	}
 	return re
 }
 
-// NewRevisionFromId returns a new Revision and sets the
-// identifier to the value passed as argument.
-func NewRevisionFromId(id int64) *Revision {
-	re := &Revision{
-		Id: id,
+// NewRevisionFromFile returns a Revision loaded from a revision info file.
+func NewRevisionFromFile(name string) (*Revision, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
 	}
-	return re
+
+	re := &Revision{}
+	if err := yaml.Unmarshal(data, re); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return re, nil
+}
+
+// Save writes the revision info to a file.
+func (re *Revision) Save(name string) error {
+	data, err := yaml.Marshal(re)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, data, 0644)
 }
 
 // AddTag links a Tag to the this revision, if not already the case.
@@ -55,10 +81,11 @@ func (re *Revision) DeleteTag(t *Tag) bool {
 	return ok
 }
 
-// Timestamp returns a custom formatted date/time string.
+// Timestamp returns a custom formatted date/time string of the creation
+// time.
 func (re *Revision) Timestamp() string {
-	year, month, day := time.Unix(int64(re.Id), 0).Date()
-	hour, min, sec := time.Unix(int64(re.Id), 0).Clock()
+	year, month, day := re.Created.Local().Date()
+	hour, min, sec := re.Created.Local().Clock()
 	return fmt.Sprintf("%v-%02d-%02v %02v:%02v:%02v", year, month, day, hour, min, sec)
 }
 
@@ -72,6 +99,18 @@ func (re *Revision) TagNames() []string {
 	return tagNames
 }
 
+// hasAnyTag returns true when the revision carries one of the tags.
+func (re *Revision) hasAnyTag(tagNames ...string) bool {
+	for _, t := range re.Tags {
+		for _, name := range tagNames {
+			if t.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // getTagIndex returns the index of the Tag passed as argument.
 // The boolean will be set to true when a proper index was found,
 // false when not.
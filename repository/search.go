@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"github.com/catay/rrst/repository/repomd"
+	"sort"
+)
+
+// A PackageMatch is a version of a package found by Search, with the tags
+// of the revisions holding it.
+type PackageMatch struct {
+	Name    string
+	Arch    string
+	Version repomd.EVR
+	Tags    []string
+}
+
+// The Search method returns the versions of the packages of which the name
+// matches, sorted by name, arch and version. Only the tagged revisions are
+// searched, limited to the revisions of the given tags when present. Each
+// revision is read once, no matter how many tags it carries.
+func (r *Repository) Search(match func(name string) bool, tags ...string) ([]PackageMatch, error) {
+	var revisions []*Revision
+	for _, rev := range r.Revisions {
+		if len(rev.Tags) == 0 {
+			continue
+		}
+
+		if len(tags) == 0 || rev.hasAnyTag(tags...) {
+			revisions = append(revisions, rev)
+		}
+	}
+
+	matches := make(map[string]*PackageMatch)
+	for _, rev := range revisions {
+		packages, err := r.getPackageIndex(rev)
+		if err != nil {
+			return nil, err
+		}
+
+		seen := make(map[string]bool)
+		for _, p := range packages {
+			if !match(p.Name) {
+				continue
+			}
+
+			key := p.Name + "." + p.Arch + "-" + p.Version.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			m, ok := matches[key]
+			if !ok {
+				m = &PackageMatch{Name: p.Name, Arch: p.Arch, Version: p.Version}
+				matches[key] = m
+			}
+			m.Tags = append(m.Tags, rev.TagNames()...)
+		}
+	}
+
+	var result []PackageMatch
+	for _, m := range matches {
+		sort.Strings(m.Tags)
+		result = append(result, *m)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Arch != b.Arch {
+			return a.Arch < b.Arch
+		}
+		return repomd.CompareEVR(a.Version, b.Version) < 0
+	})
+
+	return result, nil
+}
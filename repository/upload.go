@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	rpmLeadMagic   = []byte{0xed, 0xab, 0xee, 0xdb}
+	rpmHeaderMagic = []byte{0x8e, 0xad, 0xe8}
+)
+
+const (
+	rpmLeadSize = 96
+)
+
+// Errors returned by AddPackage.
+var (
+	ErrNotLocalRepository = errors.New("not a local repository")
+	ErrInvalidFileName    = errors.New("file name should have the .rpm extension")
+	ErrNotRpmPackage      = errors.New("not a valid RPM package")
+	ErrPackageConflict    = errors.New("package already exists with different content")
+)
+
+// The AddPackage method stores an uploaded RPM package in the files
+// directory of a local repository. The content is validated to be an RPM
+// package first. It returns true when the package was stored, false when
+// an identical package was already present.
+func (r *Repository) AddPackage(filename string, content io.Reader) (bool, error) {
+	if r.RemoteURI != "" {
+		return false, ErrNotLocalRepository
+	}
+
+	name := filepath.Base(filename)
+	if !strings.HasSuffix(name, ".rpm") || strings.HasPrefix(name, ".") {
+		return false, ErrInvalidFileName
+	}
+
+	br := bufio.NewReader(content)
+	lead, err := br.Peek(rpmLeadSize + len(rpmHeaderMagic))
+	if err != nil || !isRpmLead(lead) {
+		return false, ErrNotRpmPackage
+	}
+
+	tmpfile := r.ContentTmpPath + "/" + name + tmpSuffix
+	f, err := os.Create(tmpfile)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmpfile)
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), br); err != nil {
+		return false, err
+	}
+
+	if err := f.Close(); err != nil {
+		return false, err
+	}
+
+	target := r.ContentFilesPath + "/" + name
+	if _, err := os.Stat(target); err == nil {
+		sum, err := sha256File(target)
+		if err != nil {
+			return false, err
+		}
+		if sum != fmt.Sprintf("%x", h.Sum(nil)) {
+			return false, ErrPackageConflict
+		}
+		return false, nil
+	}
+
+	if err := r.checkLockedPackage(name, tmpfile); err != nil {
+		return false, err
+	}
+
+	if err := os.Rename(tmpfile, target); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// The Refresh method creates a new revision for a local repository when
+// the content of the files directory changed, tags it as latest and
+// applies the tag rules.
+func (r *Repository) Refresh() error {
+	if r.RemoteURI != "" {
+		return ErrNotLocalRepository
+	}
+
+	if _, err := r.updateFromLocal(0); err != nil {
+		return err
+	}
+
+	_, err := r.updateTags()
+	return err
+}
+
+// isRpmLead checks if the data starts with an RPM lead followed by the
+// signature header.
+func isRpmLead(data []byte) bool {
+	if len(data) < rpmLeadSize+len(rpmHeaderMagic) {
+		return false
+	}
+	return bytes.HasPrefix(data, rpmLeadMagic) && bytes.HasPrefix(data[rpmLeadSize:], rpmHeaderMagic)
+}
+
+// sha256File returns the hex encoded SHA-256 checksum of a file.
+func sha256File(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
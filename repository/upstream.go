@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"fmt"
+	"github.com/catay/rrst/config"
+	"github.com/catay/rrst/repository/repomd"
+	"strings"
+	"time"
+)
+
+// updateFromUpstreamTags syncs the revisions the upstream tags of another
+// rrst server point to and recreates those tags locally. A revision is
+// only created when no revision with the same metadata exists yet, so a
+// tag moving back to an earlier revision reuses the local one.
+func (r *Repository) updateFromUpstreamTags() error {
+	if len(r.UpstreamTags) == 0 {
+		return fmt.Errorf("no upstream_tags configured for repository %s", r.Name)
+	}
+
+	var failed []string
+	for _, tagname := range r.UpstreamTags {
+		if err := r.updateFromUpstreamTag(tagname); err != nil {
+			fmt.Printf("%v: upstream tag %v: %v\n", r.Name, tagname, err)
+			failed = append(failed, tagname)
+		}
+	}
+
+	if len(failed) > 0 {
+		// Still tag the revisions of the upstream tags which did sync.
+		if _, err := r.tagLatestRevision(config.DefaultLatestRevisionTag); err != nil {
+			return err
+		}
+		return fmt.Errorf("syncing upstream tags %v failed", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// updateFromUpstreamTag syncs the revision of a single upstream tag.
+func (r *Repository) updateFromUpstreamTag(tagname string) error {
+	start := time.Now()
+	uri := strings.TrimSuffix(r.RemoteURI, "/") + "/" + tagname
+
+	current, err := r.getUpstreamMetadata(uri)
+	if err != nil {
+		return err
+	}
+
+	// The metadata is downloaded before the revision directory is renamed
+	// into place, so an interrupted sync never leaves a revision with
+	// incomplete metadata behind.
+	rev := r.revisionByUpstreamMetadata(current)
+	if rev == nil {
+		rev, err = r.createRevisionWith(func(dir string) error {
+			return r.downloadMetadata(dir, uri, current)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !rev.IsLocked() {
+		downloaded, err := r.getPackages(rev, uri)
+		if err != nil {
+			return err
+		}
+
+		if err := r.writeManifest(rev, uri, downloaded, time.Since(start)); err != nil {
+			return err
+		}
+	}
+
+	// The latest tag is managed by the update itself.
+	if tagname == config.DefaultLatestRevisionTag {
+		return nil
+	}
+
+	_, err = r.tag(tagname, rev.Id, "synced from "+uri)
+	return err
+}
+
+// revisionByUpstreamMetadata returns the most recent revision with the
+// same metadata as the upstream one. The returned Revision will be nil
+// when not found.
+func (r *Repository) revisionByUpstreamMetadata(current *repomd.RepomdXML) *Revision {
+	for i := len(r.Revisions) - 1; i >= 0; i-- {
+		rm, err := r.getLocalMetadata(r.Revisions[i])
+		if err == nil && rm.Compare(current) {
+			return r.Revisions[i]
+		}
+	}
+	return nil
+}
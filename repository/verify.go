@@ -0,0 +1,318 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"github.com/catay/rrst/repository/repomd"
+	h "github.com/catay/rrst/util/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Problems reported by Verify.
+const (
+	ProblemMissing = "missing"
+	ProblemCorrupt = "corrupt"
+	ProblemExtra   = "extra"
+)
+
+// ErrNoUpstream is returned by Repair for local repositories.
+var ErrNoUpstream = errors.New("repository has no upstream to repair from")
+
+// A VerifyProblem describes a file failing the verification of a
+// revision. Path is relative to the revision directory for metadata files
+// and relative to the files directory for packages. Revision is 0 for
+// package files not referenced by any revision.
+type VerifyProblem struct {
+	Revision int64
+	Path     string
+	Package  bool
+	Problem  string
+	Detail   string
+	Repaired bool
+
+	checksum repomdChecksum
+	size     int64
+}
+
+// repomdChecksum is a checksum as listed in the repomd metadata.
+type repomdChecksum struct {
+	Type  string
+	Value string
+}
+
+// The Verify method checks the repodata files of a revision, referenced
+// by tag or revision id, against the checksums in its repomd.xml and the
+// packages against the size and checksum in primary.xml. Without tag or
+// revision all revisions are verified and the package files not
+// referenced by any revision are reported as extra, as long as the
+// package lists of all revisions could be read.
+func (r *Repository) Verify(tagOrRev string) ([]VerifyProblem, error) {
+	var problems []VerifyProblem
+
+	revisions := r.Revisions
+	if tagOrRev != "" {
+		rev := r.revisionByTagOrRevId(tagOrRev)
+		if rev == nil {
+			return nil, fmt.Errorf("tag or revision %s not found", tagOrRev)
+		}
+		revisions = []*Revision{rev}
+	}
+
+	// Packages are shared between revisions, check each of them once.
+	checked := make(map[string]*VerifyProblem)
+	referenced := make(map[string]bool)
+
+	// Extra package files can only be determined when the packages of
+	// all revisions are known.
+	complete := true
+
+	for _, rev := range revisions {
+		p, err := r.verifyMetadata(rev)
+		if err != nil {
+			return nil, fmt.Errorf("revision %v: %s", rev.Id, err)
+		}
+		problems = append(problems, p...)
+
+		// The packages can't be checked when primary.xml is unreadable,
+		// which is already reported by the metadata verification.
+		packages, err := r.getMetadataPackageList(rev)
+		if err != nil {
+			if len(p) == 0 {
+				return nil, fmt.Errorf("revision %v: %s", rev.Id, err)
+			}
+			complete = false
+			continue
+		}
+
+		for _, v := range packages {
+			path := v.Location.Path
+			referenced[path] = true
+
+			result, ok := checked[path]
+			if !ok {
+				result = r.verifyFile(r.ContentFilesPath+"/"+path, repomdChecksum{v.Checksum.Type, v.Checksum.Value}, v.Size.Package)
+				checked[path] = result
+			}
+
+			if result != nil {
+				p := *result
+				p.Revision = rev.Id
+				p.Path = path
+				p.Package = true
+				problems = append(problems, p)
+			}
+		}
+	}
+
+	if tagOrRev == "" && complete {
+		extra, err := r.extraPackageFiles(referenced)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, extra...)
+	}
+
+	return problems, nil
+}
+
+// The Repair method downloads the missing and corrupt files of the
+// verification problems again from upstream. The repaired problems are
+// marked as such. Extra files are left alone and local repositories
+// can't be repaired, as they have no upstream.
+func (r *Repository) Repair(problems []VerifyProblem) error {
+	if r.RemoteURI == "" {
+		return ErrNoUpstream
+	}
+
+	for i, p := range problems {
+		if p.Problem == ProblemExtra {
+			continue
+		}
+
+		if p.checksum.Type == "" {
+			problems[i].Detail = "can't be repaired"
+			continue
+		}
+
+		rev := r.revisionById(p.Revision)
+		if rev == nil {
+			continue
+		}
+
+		uri := r.getRevisionSource(rev)
+		target := r.getRevisionDir(rev) + "/" + p.Path
+		if p.Package {
+			target = r.ContentFilesPath + "/" + p.Path
+		}
+
+		// A package shared between revisions only has to be fetched once.
+		if p.Package && r.verifyFile(target, p.checksum, p.size) == nil {
+			problems[i].Repaired = true
+			continue
+		}
+
+		if err := r.repairFile(uri+"/"+p.Path, target, p.checksum, p.size); err != nil {
+			problems[i].Detail = "repair failed: " + err.Error()
+			continue
+		}
+		problems[i].Repaired = true
+	}
+
+	return nil
+}
+
+// verifyMetadata checks the repodata files of a revision.
+func (r *Repository) verifyMetadata(rev *Revision) ([]VerifyProblem, error) {
+	var problems []VerifyProblem
+
+	rm, err := r.getLocalMetadata(rev)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []VerifyProblem{{Revision: rev.Id, Path: strings.TrimPrefix(repoXMLfile, "/"), Problem: ProblemMissing}}, nil
+		}
+		return nil, err
+	}
+
+	listed := make(map[string]bool)
+	for _, v := range rm.Data {
+		listed[v.Location.Path] = true
+
+		var size int64
+		fmt.Sscan(v.Size, &size)
+
+		result := r.verifyFile(r.getRevisionDir(rev)+"/"+v.Location.Path, repomdChecksum{v.CheckSum.Type, v.CheckSum.Value}, size)
+		if result != nil {
+			result.Revision = rev.Id
+			result.Path = v.Location.Path
+			problems = append(problems, *result)
+		}
+	}
+
+	files, err := r.getRepodataFiles(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range files {
+		if "/"+v != repoXMLfile && !listed[v] {
+			problems = append(problems, VerifyProblem{Revision: rev.Id, Path: v, Problem: ProblemExtra})
+		}
+	}
+
+	return problems, nil
+}
+
+// verifyFile checks the size and checksum of a file. A size of 0 is not
+// checked. It returns nil when the file is fine.
+func (r *Repository) verifyFile(name string, checksum repomdChecksum, size int64) *VerifyProblem {
+	p := &VerifyProblem{checksum: checksum, size: size}
+
+	fi, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		p.Problem = ProblemMissing
+		return p
+	}
+
+	if err != nil {
+		p.Problem = ProblemCorrupt
+		p.Detail = err.Error()
+		return p
+	}
+
+	if size > 0 && fi.Size() != size {
+		p.Problem = ProblemCorrupt
+		p.Detail = fmt.Sprintf("size %v, expected %v", fi.Size(), size)
+		return p
+	}
+
+	sum, err := repomd.FileChecksum(checksum.Type, name)
+	if err != nil {
+		p.Problem = ProblemCorrupt
+		p.Detail = err.Error()
+		return p
+	}
+
+	if sum != checksum.Value {
+		p.Problem = ProblemCorrupt
+		p.Detail = checksum.Type + " checksum mismatch"
+		return p
+	}
+
+	return nil
+}
+
+// repairFile downloads a file in the tmp directory and moves it into
+// place when its size and checksum are correct.
+func (r *Repository) repairFile(url string, target string, checksum repomdChecksum, size int64) error {
+	tmpfile := r.ContentTmpPath + "/" + filepath.Base(target)
+	defer os.Remove(tmpfile)
+
+	if err := h.HttpGetFile(r.providerURLconversion(url), tmpfile); err != nil {
+		return err
+	}
+
+	if p := r.verifyFile(tmpfile, checksum, size); p != nil {
+		return fmt.Errorf("downloaded file is %s too", p.Problem)
+	}
+
+	if rel, err := filepath.Rel(r.ContentFilesPath, target); err == nil && !strings.HasPrefix(rel, "..") {
+		if err := r.checkLockedPackage(rel, tmpfile); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpfile, target)
+}
+
+// extraPackageFiles returns the package files in the files directory not
+// referenced by any revision.
+func (r *Repository) extraPackageFiles(referenced map[string]bool) ([]VerifyProblem, error) {
+	var problems []VerifyProblem
+
+	err := filepath.Walk(r.ContentFilesPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() || strings.HasSuffix(path, tmpSuffix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(r.ContentFilesPath, path)
+		if err != nil {
+			return err
+		}
+
+		if !referenced[rel] {
+			problems = append(problems, VerifyProblem{Path: rel, Package: true, Problem: ProblemExtra})
+		}
+		return nil
+	})
+
+	if os.IsNotExist(err) {
+		err = nil
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+
+	return problems, err
+}
+
+// getRevisionSource returns the upstream URL of a revision. That's the
+// source recorded in the manifest, which includes the tag for revisions
+// synced from another rrst server, or the remote URI of the repository.
+func (r *Repository) getRevisionSource(rev *Revision) string {
+	if m := rev.Manifest; m != nil && m.Source != "" && !strings.HasPrefix(m.Source, "file://") {
+		return m.Source
+	}
+	return r.RemoteURI
+}
@@ -2,40 +2,50 @@ package server
 
 import (
 	"fmt"
+	"github.com/catay/rrst/config"
 	"github.com/catay/rrst/repository"
 	"github.com/fsnotify/fsnotify"
 	"log"
 	"net"
 	"net/http"
+	"sync"
 )
 
 // Server model
 type Server struct {
 	http.Server
 	RepoHandleStateTrackers []*RepoHandleStateTracker
+	UploadHandler           *UploadHandler
 	totalActiveRequests     int64
 	watcher                 *fsnotify.Watcher
 }
 
 // RepoHandleStateTracker tracks Tag handle state for the repositories
+//
+// The repository state is refreshed by the tag watcher and by package
+// uploads on different goroutines, mu keeps them apart.
 type RepoHandleStateTracker struct {
 	*repository.Repository
 	TagHandleStateTrackers map[string]*TagHandleStateTracker
+	mu                     *sync.Mutex
 }
 
 // TagHandleState tracks HTTP handler state
 //
 // Mainly done because we cannot deregister a HTTP handler or
-// redefine it.
+// redefine it. A handler of a removed tag stays registered, but
+// Served is set to false and requests get a not found reply.
 type TagHandleStateTracker struct {
 	Present    bool
 	Registered bool
+	Served     bool
 }
 
 func NewRepoHandleStateTracker(repo *repository.Repository) *RepoHandleStateTracker {
 	rh := &RepoHandleStateTracker{
 		Repository:             repo,
 		TagHandleStateTrackers: make(map[string]*TagHandleStateTracker),
+		mu:                     &sync.Mutex{},
 	}
 	return rh
 }
@@ -52,7 +62,7 @@ func (rh *RepoHandleStateTracker) UpdatePresentFlag() {
 		if ok {
 			rh.TagHandleStateTrackers[t.Name].Present = true
 		} else {
-			rh.TagHandleStateTrackers[t.Name] = &TagHandleStateTracker{true, false}
+			rh.TagHandleStateTrackers[t.Name] = &TagHandleStateTracker{true, false, false}
 		}
 	}
 }
@@ -60,14 +70,14 @@ func (rh *RepoHandleStateTracker) UpdatePresentFlag() {
 func (rh *RepoHandleStateTracker) refreshHandlers() {
 	for k, v := range rh.TagHandleStateTrackers {
 
-		if v.Present && v.Registered {
+		if v.Present && v.Registered && v.Served {
 			log.Printf("Tag %v already registered ", k)
 		}
 
 		if v.Present && !v.Registered {
 			// register handle to serve the metadata
 			serveMdPath := "/" + rh.ContentSuffixPath + "/" + k + "/repodata/"
-			localMdPath := rh.ContentTagsPath + "/" + k + "/repodata/"
+			localMdPath := rh.TagPath(k) + "/repodata/"
 
 			http.Handle(serveMdPath, HTTPLogger(
 				rh.serveTag(http.StripPrefix(serveMdPath,
@@ -84,14 +94,25 @@ func (rh *RepoHandleStateTracker) refreshHandlers() {
 			))
 
 			rh.TagHandleStateTrackers[k].Registered = true
+			rh.TagHandleStateTrackers[k].Served = true
 			log.Println("register " + k + " url: " + serveFilesPath)
 		}
+
+		if v.Present && v.Registered && !v.Served {
+			v.Served = true
+			log.Println("register " + k + " url: /" + rh.ContentSuffixPath + "/" + k + "/")
+		}
+
+		if !v.Present && v.Served {
+			v.Served = false
+			log.Println("unregister " + k + " url: /" + rh.ContentSuffixPath + "/" + k + "/")
+		}
 	}
 }
 
 func (rh *RepoHandleStateTracker) serveTag(h http.Handler, tag string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if rh.TagHandleStateTrackers[tag].Present {
+		if rh.TagHandleStateTrackers[tag].Served {
 			h.ServeHTTP(w, r)
 		} else {
 			http.NotFound(w, r)
@@ -100,7 +121,8 @@ func (rh *RepoHandleStateTracker) serveTag(h http.Handler, tag string) http.Hand
 }
 
 // NewServer returns a new server.
-func NewServer(port string, repositories []*repository.Repository) *Server {
+// Package uploads are only enabled when users are configured.
+func NewServer(port string, repositories []*repository.Repository, serverConfig config.ServerConfig) *Server {
 	s := &Server{
 		Server: http.Server{
 			Addr: ":" + port,
@@ -115,8 +137,15 @@ func NewServer(port string, repositories []*repository.Repository) *Server {
 	}
 
 	// init repo handle state tracker
+	locks := make(map[string]*sync.Mutex)
 	for i, _ := range repositories {
-		s.RepoHandleStateTrackers = append(s.RepoHandleStateTrackers, NewRepoHandleStateTracker(repositories[i]))
+		rh := NewRepoHandleStateTracker(repositories[i])
+		s.RepoHandleStateTrackers = append(s.RepoHandleStateTrackers, rh)
+		locks[rh.Name] = rh.mu
+	}
+
+	if len(serverConfig.Users) > 0 {
+		s.UploadHandler = NewUploadHandler(serverConfig.Users, repositories, locks)
 	}
 
 	return s
@@ -136,9 +165,9 @@ func (s *Server) config(w http.ResponseWriter, r *http.Request) {
 	for i, _ := range s.RepoHandleStateTrackers {
 
 		fmt.Fprintf(w, "* %v\n", s.RepoHandleStateTrackers[i].Name)
-		fmt.Fprintf(w, "%-10v %-10v %v\n", "Handle", "Present", "Registered")
+		fmt.Fprintf(w, "%-10v %-10v %-10v %v\n", "Handle", "Present", "Registered", "Served")
 		for k, v := range s.RepoHandleStateTrackers[i].TagHandleStateTrackers {
-			fmt.Fprintf(w, "%-10v %-10v %v\n", k, v.Present, v.Registered)
+			fmt.Fprintf(w, "%-10v %-10v %-10v %v\n", k, v.Present, v.Registered, v.Served)
 
 		}
 		fmt.Fprintf(w, "\n")
@@ -168,6 +197,11 @@ func (s *Server) Run() error {
 
 	http.HandleFunc("/config", s.config)
 
+	if s.UploadHandler != nil {
+		http.Handle(uploadPath, HTTPLogger(s.UploadHandler))
+		log.Println("register upload url: " + uploadPath)
+	}
+
 	return s.ListenAndServe()
 }
 
@@ -177,12 +211,6 @@ func (s *Server) resetPresentFlag() {
 	}
 }
 
-func (s *Server) updatePresentFlag() {
-	for i, _ := range s.RepoHandleStateTrackers {
-		s.RepoHandleStateTrackers[i].UpdatePresentFlag()
-	}
-}
-
 func (s *Server) refreshHandlers() {
 	for i, _ := range s.RepoHandleStateTrackers {
 		s.RepoHandleStateTrackers[i].refreshHandlers()
@@ -194,14 +222,17 @@ func (s *Server) registerHandlers() {
 	// Set Present boolean in all the tag handles to false
 	s.resetPresentFlag()
 
-	// refresh the repository state
+	// Refresh the repository state and check which tags are in the map
+	// and put presence to true. The lock keeps uploads from changing the
+	// state meanwhile.
 	for i, _ := range s.RepoHandleStateTrackers {
-		s.RepoHandleStateTrackers[i].RefreshState()
+		rh := s.RepoHandleStateTrackers[i]
+		rh.mu.Lock()
+		rh.RefreshState()
+		rh.UpdatePresentFlag()
+		rh.mu.Unlock()
 	}
 
-	// Check which files are in the map and put presence to true.
-	s.updatePresentFlag()
-
 	s.refreshHandlers()
 }
 
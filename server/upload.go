@@ -0,0 +1,137 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"github.com/catay/rrst/config"
+	"github.com/catay/rrst/repository"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	uploadPath = "/upload/"
+)
+
+// UploadHandler accepts authenticated package uploads into local
+// repositories.
+//
+// Packages are uploaded with a PUT or POST request to
+// /upload/<repo name>/<package file name>. The refresh=true query
+// parameter creates a new repository revision after storing the package.
+//
+// The locks hold a mutex per repository name, shared with the tag watcher
+// of the server, as both change the state of the repository.
+type UploadHandler struct {
+	users        []*config.User
+	repositories []*repository.Repository
+	locks        map[string]*sync.Mutex
+}
+
+// NewUploadHandler returns a new UploadHandler.
+func NewUploadHandler(users []*config.User, repositories []*repository.Repository, locks map[string]*sync.Mutex) *UploadHandler {
+	return &UploadHandler{
+		users:        users,
+		repositories: repositories,
+		locks:        locks,
+	}
+}
+
+func (u *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "PUT, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := u.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="rrst"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, uploadPath), "/", 2)
+	if len(parts) != 2 || parts[1] == "" || strings.Contains(parts[1], "/") {
+		http.Error(w, "expected "+uploadPath+"<repo name>/<package file name>", http.StatusBadRequest)
+		return
+	}
+
+	repo := u.repositoryByName(parts[0])
+	if repo == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Serialize uploads and tag watcher refreshes of a repository so
+	// revisions don't get created concurrently and the repository state
+	// isn't rebuilt while it's read.
+	mu := u.locks[repo.Name]
+	mu.Lock()
+	defer mu.Unlock()
+
+	stored, err := repo.AddPackage(parts[1], r.Body)
+	switch err {
+	case nil:
+	case repository.ErrNotLocalRepository, repository.ErrInvalidFileName, repository.ErrNotRpmPackage:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case repository.ErrPackageConflict, repository.ErrPackageLocked:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		log.Printf("upload %v/%v failed: %v", repo.Name, parts[1], err)
+		http.Error(w, "upload failed", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("upload %v/%v by %v stored: %v", repo.Name, parts[1], user, stored)
+
+	if r.URL.Query().Get("refresh") == "true" {
+		if err := repo.Refresh(); err != nil {
+			log.Printf("refresh %v failed: %v", repo.Name, err)
+			http.Error(w, "refresh failed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if stored {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%v stored\n", parts[1])
+	} else {
+		fmt.Fprintf(w, "%v already present\n", parts[1])
+	}
+}
+
+// authenticate checks the basic authentication credentials against the
+// configured users and returns the user name when valid.
+func (u *UploadHandler) authenticate(r *http.Request) (string, bool) {
+	name, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+
+	for _, v := range u.users {
+		if v.Password == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(name), []byte(v.Name)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(v.Password)) == 1 {
+			return v.Name, true
+		}
+	}
+	return "", false
+}
+
+// repositoryByName returns the repository with the matching name or nil
+// when not found.
+func (u *UploadHandler) repositoryByName(name string) *repository.Repository {
+	for i, r := range u.repositories {
+		if r.Name == name {
+			return u.repositories[i]
+		}
+	}
+	return nil
+}
@@ -1,7 +1,13 @@
 package file
 
 import (
+	"io"
 	"os"
+	"path/filepath"
+)
+
+const (
+	tmpSuffix = ".filepart"
 )
 
 func IsRegularFile(name string) bool {
@@ -44,3 +50,58 @@ func FirstExistingFile(list []string) string {
 	}
 	return saved
 }
+
+// CopyFile copies the content of the src file to the dst file. The
+// parent directories of dst are created when missing. The copy is
+// written to a temporary file first and renamed when complete.
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst + tmpSuffix)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chtimes(dst+tmpSuffix, fi.ModTime(), fi.ModTime()); err != nil {
+		return err
+	}
+
+	return os.Rename(dst+tmpSuffix, dst)
+}
+
+// LinkOrCopyFile creates a hard link dst pointing to src. When the hard
+// link can't be created, for example across filesystems, it falls back
+// to copying the file.
+func LinkOrCopyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	return CopyFile(src, dst)
+}
@@ -5,6 +5,8 @@ import (
 	. "github.com/onsi/gomega"
 
 	. "github.com/catay/rrst/util/file"
+	"io/ioutil"
+	"os"
 )
 
 // Feature: file package
@@ -80,4 +82,37 @@ var _ = Describe("File package: ", func() {
 			})
 		})
 	})
+
+	Describe("Given a function CopyFile(src, dst string)", func() {
+		var tmpDir string
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "rrst-file-test")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpDir)
+		})
+
+		Context("when passing an existing regular file as source", func() {
+			It("should copy the content into a new file", func() {
+				dst := tmpDir + "/sub/dir/copy.txt"
+				Expect(CopyFile(existingFileName, dst)).To(Succeed())
+				Expect(IsRegularFile(dst)).To(BeTrue())
+				Expect(IsRegularFile(dst + ".filepart")).To(BeFalse())
+
+				src, _ := ioutil.ReadFile(existingFileName)
+				copied, _ := ioutil.ReadFile(dst)
+				Expect(copied).To(Equal(src))
+			})
+		})
+
+		Context("when passing a non-existing regular file as source", func() {
+			It("should return an error", func() {
+				Expect(CopyFile(nonExistingFileName, tmpDir+"/copy.txt")).NotTo(Succeed())
+			})
+		})
+	})
 })
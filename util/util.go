@@ -5,6 +5,10 @@ import (
 	"fmt"
 )
 
+const (
+	unit = 1024
+)
+
 func Sha256Sum(s string) string {
 
 	h := sha256.New()
@@ -13,3 +17,18 @@ func Sha256Sum(s string) string {
 
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
+
+// HumanBytes returns the size in bytes as a human readable string,
+// for example 1.5 GiB.
+func HumanBytes(n int64) string {
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}